@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	big_cache "inmem/lib/inmem-cache/big-cache"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"inmem/lib/loadgen"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	adaptor := flag.String("adaptor", "bigcache", "cache adaptor: bigcache or map")
+	shards := flag.Int("shards", 64, "bigcache shards, power of two")
+	memoryMB := flag.Int("memory-mb", 64, "bigcache hard memory limit in MB")
+	ttl := flag.Duration("ttl", 5*time.Second, "cache ttl")
+	staleWindow := flag.Duration("stale", 0, "serve stale window, 0 disables it")
+	qps := flag.Int("qps", 5000, "target operations per second, 0 is unthrottled")
+	duration := flag.Duration("duration", 30*time.Second, "how long to run")
+	workers := flag.Int("workers", 64, "concurrent workers")
+	keys := flag.Int("keys", 10000, "distinct keys")
+	zipf := flag.Float64("zipf", 1.1, "zipf exponent, 0 for uniform keys")
+	read := flag.Float64("read", 0.8, "share of reads")
+	write := flag.Float64("write", 0.15, "share of writes")
+	del := flag.Float64("delete", 0.05, "share of deletes")
+	loaderLatency := flag.Duration("loader-latency", 5*time.Millisecond, "mock loader latency")
+	loaderErrors := flag.Float64("loader-errors", 0, "share of mock loads that fail")
+	valueSize := flag.Int("value-size", 256, "value size in bytes")
+	asJSON := flag.Bool("json", false, "print the report as json")
+	flag.Parse()
+
+	var cacheAdaptor cache.CacheAdaptorServiceContract
+	switch *adaptor {
+	case "bigcache":
+		cacheAdaptor = big_cache.CreateBigCache(
+			big_cache.WithShards(*shards),
+			big_cache.WithCacheMemoryLimit(*memoryMB),
+		)
+	case "map":
+		cacheAdaptor = map_cache.CreateMapCache()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown adaptor %q\n", *adaptor)
+		os.Exit(2)
+	}
+	c := cache.GetCache(cacheAdaptor, *ttl, true)
+
+	options := []loadgen.OptionalWorkloadConfig{
+		loadgen.WithQPS(*qps),
+		loadgen.WithDuration(*duration),
+		loadgen.WithWorkers(*workers),
+		loadgen.WithKeys(*keys),
+		loadgen.WithZipf(*zipf),
+		loadgen.WithRatios(*read, *write, *del),
+		loadgen.WithLoaderLatency(*loaderLatency),
+		loadgen.WithLoaderErrorRate(*loaderErrors),
+		loadgen.WithValueSize(*valueSize),
+	}
+	if *staleWindow > 0 {
+		options = append(options, loadgen.WithStaleWindow(*staleWindow))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	report, err := loadgen.Run(ctx, c, options...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(report)
+		return
+	}
+	fmt.Print(report)
+}
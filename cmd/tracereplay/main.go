@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	big_cache "inmem/lib/inmem-cache/big-cache"
+	"inmem/lib/inmem-cache/clock"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"inmem/lib/inmem-cache/trace"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	tracePath := flag.String("trace", "", "trace file written by trace.Recorder")
+	adaptors := flag.String("adaptors", "bigcache,map", "comma separated adaptors to compare: bigcache, map")
+	ttls := flag.String("ttls", "5s", "comma separated ttls to compare")
+	shards := flag.Int("shards", 64, "bigcache shards, power of two")
+	memoryMB := flag.Int("memory-mb", 64, "bigcache hard memory limit in MB")
+	asJSON := flag.Bool("json", false, "print the results as json")
+	flag.Parse()
+	if *tracePath == "" {
+		fmt.Fprintln(os.Stderr, "-trace is required")
+		os.Exit(2)
+	}
+
+	factories := make(map[string]trace.Factory)
+	for _, ttlFlag := range strings.Split(*ttls, ",") {
+		ttl, err := time.ParseDuration(strings.TrimSpace(ttlFlag))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid ttl %q: %v\n", ttlFlag, err)
+			os.Exit(2)
+		}
+		for _, adaptor := range strings.Split(*adaptors, ",") {
+			adaptor = strings.TrimSpace(adaptor)
+			var newAdaptor func() cache.CacheAdaptorServiceContract
+			switch adaptor {
+			case "bigcache":
+				newAdaptor = func() cache.CacheAdaptorServiceContract {
+					return big_cache.CreateBigCache(
+						big_cache.WithShards(*shards),
+						big_cache.WithCacheMemoryLimit(*memoryMB),
+					)
+				}
+			case "map":
+				newAdaptor = func() cache.CacheAdaptorServiceContract {
+					return map_cache.CreateMapCache()
+				}
+			default:
+				fmt.Fprintf(os.Stderr, "unknown adaptor %q\n", adaptor)
+				os.Exit(2)
+			}
+			factories[adaptor+"/ttl="+ttl.String()] = func(clk clock.Clock) *cache.Cache {
+				return cache.GetCache(newAdaptor(), ttl, true, cache.WithClock(clk))
+			}
+		}
+	}
+
+	results, err := trace.Compare(*tracePath, factories)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(results)
+		return
+	}
+	for _, result := range results {
+		fmt.Println(result)
+	}
+}
@@ -7,4 +7,5 @@ toolchain go1.24.9
 require (
 	github.com/allegro/bigcache/v3 v3.1.0
 	golang.org/x/sync v0.17.0
+	gopkg.in/yaml.v3 v3.0.1
 )
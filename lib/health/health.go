@@ -0,0 +1,177 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultCheckTimeout = 2 * time.Second
+
+var ErrCheckTimeout = errors.New("health check timed out")
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Check returns nil when the component is healthy, it should honour ctx
+type Check func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status   Status `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status     Status                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Registry holds liveness checks, served on /healthz, and readiness checks, served on /readyz.
+// A component that can't serve traffic yet or anymore registers a readiness check, a component
+// that needs a restart to recover registers a liveness check.
+type Registry struct {
+	mu           sync.RWMutex
+	liveness     map[string]Check
+	readiness    map[string]Check
+	checkTimeout time.Duration
+}
+
+type OptionalRegistryConfig func(r *Registry)
+
+func WithCheckTimeout(timeout time.Duration) OptionalRegistryConfig {
+	return func(r *Registry) {
+		r.checkTimeout = timeout
+	}
+}
+
+func NewRegistry(optionalRegistryConfigs ...OptionalRegistryConfig) *Registry {
+	registry := &Registry{
+		liveness:     make(map[string]Check),
+		readiness:    make(map[string]Check),
+		checkTimeout: defaultCheckTimeout,
+	}
+	for _, option := range optionalRegistryConfigs {
+		option(registry)
+	}
+	return registry
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default is the process wide registry the libraries register their checks on
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// AddLivenessCheck registers check under name, a second call with the same name replaces it
+func (r *Registry) AddLivenessCheck(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.liveness[name] = check
+}
+
+func (r *Registry) AddReadinessCheck(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readiness[name] = check
+}
+
+// Liveness runs the liveness checks
+func (r *Registry) Liveness(ctx context.Context) Report {
+	return r.run(ctx, r.snapshot(r.liveness))
+}
+
+// Readiness runs the liveness and the readiness checks, a dead component isn't ready either
+func (r *Registry) Readiness(ctx context.Context) Report {
+	checks := r.snapshot(r.liveness)
+	for name, check := range r.snapshot(r.readiness) {
+		checks[name] = check
+	}
+	return r.run(ctx, checks)
+}
+
+func (r *Registry) snapshot(checks map[string]Check) map[string]Check {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	copied := make(map[string]Check, len(checks))
+	for name, check := range checks {
+		copied[name] = check
+	}
+	return copied
+}
+
+func (r *Registry) run(ctx context.Context, checks map[string]Check) Report {
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := r.runCheck(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = status
+			if status.Status == StatusDown {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (r *Registry) runCheck(ctx context.Context, check Check) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, r.checkTimeout)
+	defer cancel()
+	start := time.Now()
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				result <- errors.New("health check panicked")
+			}
+		}()
+		result <- check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = ErrCheckTimeout
+	}
+	status := ComponentStatus{Status: StatusUp, Duration: time.Since(start).String()}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Handler serves /healthz and /readyz, both answer 503 when a check is down
+func (r *Registry) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, r.Liveness(req.Context()))
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, r.Readiness(req.Context()))
+	})
+	return mux
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == StatusDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
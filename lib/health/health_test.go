@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadinessIncludesLiveness(t *testing.T) {
+	registry := NewRegistry()
+	registry.AddLivenessCheck("alive", func(context.Context) error { return nil })
+	registry.AddReadinessCheck("warming", func(context.Context) error { return errors.New("not yet") })
+
+	if report := registry.Liveness(context.Background()); report.Status != StatusUp || len(report.Components) != 1 {
+		t.Fatalf("liveness = %+v, want only the liveness check, up", report)
+	}
+	report := registry.Readiness(context.Background())
+	if report.Status != StatusDown || report.Components["warming"].Error != "not yet" || report.Components["alive"].Status != StatusUp {
+		t.Fatalf("readiness = %+v, want both checks with warming down", report)
+	}
+}
+
+func TestSlowAndPanickingChecksAreDown(t *testing.T) {
+	registry := NewRegistry(WithCheckTimeout(20 * time.Millisecond))
+	registry.AddLivenessCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	registry.AddLivenessCheck("panicking", func(context.Context) error { panic("boom") })
+
+	report := registry.Liveness(context.Background())
+	if report.Components["slow"].Error != ErrCheckTimeout.Error() {
+		t.Fatalf("slow check = %+v, want a timeout", report.Components["slow"])
+	}
+	if report.Components["panicking"].Status != StatusDown {
+		t.Fatalf("panicking check = %+v, want down", report.Components["panicking"])
+	}
+}
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.AddReadinessCheck("warming", func(context.Context) error { return errors.New("not yet") })
+	handler := registry.Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("/healthz = %d, want 200", w.Code)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Fatalf("/readyz = %d %+v, want 503 down", w.Code, report)
+	}
+}
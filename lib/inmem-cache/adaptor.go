@@ -1,7 +1,34 @@
 package inmem_cache
 
+import "inmem/lib/inmem-cache/clock"
+
 type CacheAdaptorServiceContract interface {
 	Get(key string) (*CacheEntry, error)
 	Set(key string, cacheEntry *CacheEntry) error
 	Delete(key string) error
 }
+
+// EvictionNotifier is implemented by adaptors that remove entries on their own (expiry, capacity),
+// GetCache registers a callback so those removals show up as EventEvict
+type EvictionNotifier interface {
+	NotifyEvictions(onEviction func(key string, reason EvictReason))
+}
+
+// KeysContract is implemented by adaptors that can list their keys, it is required for prefix deletion
+type KeysContract interface {
+	Keys() ([]string, error)
+}
+
+// CompareAndSwapContract is implemented by adaptors that can replace an entry atomically.
+// The entry is only written when the stored version equals expectedVersion, 0 meaning the key
+// must not exist, otherwise ErrVersionConflict or ErrEntryNotFound is returned.
+// Set and Delete have to be serialized with it for the swap to be atomic.
+type CompareAndSwapContract interface {
+	CompareAndSwap(key string, expectedVersion uint64, cacheEntry *CacheEntry) error
+}
+
+// ClockContract is implemented by adaptors that expire entries on their own,
+// GetCache hands them its clock so adaptor-side expiry follows WithClock as well
+type ClockContract interface {
+	UseClock(clock clock.Clock)
+}
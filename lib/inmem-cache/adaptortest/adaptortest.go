@@ -0,0 +1,316 @@
+// Package adaptortest is a conformance suite for CacheAdaptorServiceContract implementations.
+// An adaptor proves it is compliant from its own test file:
+//
+//	func TestConformance(t *testing.T) {
+//		adaptortest.Run(t, func(t *testing.T) cache.CacheAdaptorServiceContract {
+//			return big_cache.CreateBigCache()
+//		})
+//	}
+//
+// Values are limited to what survives a JSON round trip (string, float64, bool, nil, maps and
+// slices of those) so serializing adaptors pass as well. The optional CompareAndSwapContract
+// and KeysContract are checked when the adaptor implements them.
+package adaptortest
+
+import (
+	"errors"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/clock/fakeclock"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns a new, empty adaptor for every call
+type Factory func(t *testing.T) cache.CacheAdaptorServiceContract
+
+const (
+	largeValueSize   = 256 * 1024
+	concurrentWorker = 16
+	concurrentOps    = 200
+	cacheTTL         = time.Minute
+)
+
+func Run(t *testing.T, factory Factory) {
+	t.Run("GetMissing", func(t *testing.T) { testGetMissing(t, factory(t)) })
+	t.Run("SetGet", func(t *testing.T) { testSetGet(t, factory(t)) })
+	t.Run("ValueKinds", func(t *testing.T) { testValueKinds(t, factory(t)) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, factory(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, factory(t)) })
+	t.Run("LargeValue", func(t *testing.T) { testLargeValue(t, factory(t)) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, factory(t)) })
+	t.Run("Expiry", func(t *testing.T) { testExpiry(t, factory(t)) })
+	t.Run("CompareAndSwap", func(t *testing.T) { testCompareAndSwap(t, factory(t)) })
+	t.Run("Keys", func(t *testing.T) { testKeys(t, factory(t)) })
+}
+
+func newEntry(value interface{}, version uint64) *cache.CacheEntry {
+	return &cache.CacheEntry{
+		Value:     value,
+		ExpiresAt: time.Unix(1700000000, 123456789).UTC(),
+		Version:   version,
+	}
+}
+
+func mustSet(t *testing.T, adaptor cache.CacheAdaptorServiceContract, key string, entry *cache.CacheEntry) {
+	t.Helper()
+	if err := adaptor.Set(key, entry); err != nil {
+		t.Fatalf("Set(%q) returned %v", key, err)
+	}
+}
+
+func mustGet(t *testing.T, adaptor cache.CacheAdaptorServiceContract, key string) *cache.CacheEntry {
+	t.Helper()
+	entry, err := adaptor.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q) returned %v", key, err)
+	}
+	if entry == nil {
+		t.Fatalf("Get(%q) returned a nil entry without an error", key)
+	}
+	return entry
+}
+
+func assertNotFound(t *testing.T, err error, op string) {
+	t.Helper()
+	if !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("%s returned %v, want an error matching cache.ErrEntryNotFound", op, err)
+	}
+}
+
+func testGetMissing(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	_, err := adaptor.Get("missing")
+	assertNotFound(t, err, `Get("missing")`)
+}
+
+func testSetGet(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	want := newEntry("value", 42)
+	mustSet(t, adaptor, "key", want)
+	got := mustGet(t, adaptor, "key")
+	if got.Value != want.Value {
+		t.Errorf("Value = %#v, want %#v", got.Value, want.Value)
+	}
+	if !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, want.ExpiresAt)
+	}
+	if got.Version != want.Version {
+		t.Errorf("Version = %d, want %d", got.Version, want.Version)
+	}
+}
+
+func testValueKinds(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	values := map[string]interface{}{
+		"string": "hello",
+		"empty":  "",
+		"number": 12.5,
+		"bool":   true,
+		"nil":    nil,
+		"map":    map[string]interface{}{"id": 1.0, "title": "todo", "done": false},
+		"slice":  []interface{}{"a", 2.0, true},
+	}
+	for name, value := range values {
+		mustSet(t, adaptor, name, newEntry(value, 1))
+	}
+	for name, value := range values {
+		got := mustGet(t, adaptor, name)
+		if !reflect.DeepEqual(got.Value, value) {
+			t.Errorf("%s: Value = %#v, want %#v", name, got.Value, value)
+		}
+	}
+}
+
+func testOverwrite(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	mustSet(t, adaptor, "key", newEntry("first", 1))
+	mustSet(t, adaptor, "key", newEntry("second", 2))
+	got := mustGet(t, adaptor, "key")
+	if got.Value != "second" || got.Version != 2 {
+		t.Errorf("got (%#v, %d), want (\"second\", 2)", got.Value, got.Version)
+	}
+}
+
+func testDelete(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	mustSet(t, adaptor, "key", newEntry("value", 1))
+	mustSet(t, adaptor, "other", newEntry("value", 1))
+	if err := adaptor.Delete("key"); err != nil {
+		t.Fatalf(`Delete("key") returned %v`, err)
+	}
+	_, err := adaptor.Get("key")
+	assertNotFound(t, err, `Get("key") after Delete`)
+	assertNotFound(t, adaptor.Delete("key"), `second Delete("key")`)
+	assertNotFound(t, adaptor.Delete("missing"), `Delete("missing")`)
+	mustGet(t, adaptor, "other")
+}
+
+func testLargeValue(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	want := strings.Repeat("0123456789abcdef", largeValueSize/16)
+	mustSet(t, adaptor, "large", newEntry(want, 1))
+	got := mustGet(t, adaptor, "large")
+	if got.Value != want {
+		t.Errorf("large value came back with length %d, want %d", len(fmt.Sprint(got.Value)), len(want))
+	}
+}
+
+// testConcurrent has every worker own a few keys and fight over a shared one,
+// run it with -race to catch unsynchronized adaptors
+func testConcurrent(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentWorker)
+	for worker := 0; worker < concurrentWorker; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < concurrentOps; i++ {
+				key := fmt.Sprintf("worker-%d-%d", worker, i%10)
+				value := fmt.Sprintf("%d-%d", worker, i)
+				if err := adaptor.Set(key, newEntry(value, uint64(i+1))); err != nil {
+					errs <- fmt.Errorf("Set(%q): %w", key, err)
+					return
+				}
+				entry, err := adaptor.Get(key)
+				if err != nil || entry.Value != value {
+					errs <- fmt.Errorf("Get(%q) = %v, %v after writing %q", key, entry, err, value)
+					return
+				}
+				adaptor.Set("shared", newEntry(value, uint64(i+1)))
+				if _, err = adaptor.Get("shared"); err != nil && !errors.Is(err, cache.ErrEntryNotFound) {
+					errs <- fmt.Errorf(`Get("shared"): %w`, err)
+					return
+				}
+				if i%7 == 0 {
+					if err = adaptor.Delete("shared"); err != nil && !errors.Is(err, cache.ErrEntryNotFound) {
+						errs <- fmt.Errorf(`Delete("shared"): %w`, err)
+						return
+					}
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// testExpiry drives the adaptor through a Cache on a fake clock, expiry lives in the
+// Cache but only works when the adaptor keeps ExpiresAt intact
+func testExpiry(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	fakeClock := fakeclock.New(time.Unix(1700000000, 0))
+	c := cache.GetCache(adaptor, cacheTTL, true, cache.WithClock(fakeClock))
+	t.Cleanup(c.Close)
+
+	if err := c.Set("key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	fakeClock.Advance(cacheTTL - time.Second)
+	if val, err := c.Get("key"); err != nil || val != "value" {
+		t.Fatalf("Get before expiry = %v, %v", val, err)
+	}
+	if remaining, err := c.TTL("key"); err != nil || remaining != time.Second {
+		t.Fatalf("TTL = %v, %v, want 1s", remaining, err)
+	}
+	if err := c.Touch("key", cacheTTL); err != nil {
+		t.Fatalf("Touch returned %v", err)
+	}
+	fakeClock.Advance(cacheTTL - time.Second)
+	if _, err := c.Get("key"); err != nil {
+		t.Fatalf("Get after Touch returned %v", err)
+	}
+	fakeClock.Advance(2 * time.Second)
+	if _, err := c.Get("key"); !errors.Is(err, cache.ErrStaleResponse) {
+		t.Fatalf("Get after expiry returned %v, want cache.ErrStaleResponse", err)
+	}
+
+	if err := c.SetWithTTL("short", "value", time.Second); err != nil {
+		t.Fatalf("SetWithTTL returned %v", err)
+	}
+	fakeClock.Advance(time.Second)
+	if _, err := c.TTL("short"); !errors.Is(err, cache.ErrStaleResponse) {
+		t.Fatalf("TTL of an expired entry returned %v, want cache.ErrStaleResponse", err)
+	}
+	loaded, err := c.Get("short", cache.WithStaleResponse(time.Minute, cache.WithLoader(func(key string) (interface{}, error) {
+		return "reloaded", nil
+	})))
+	if err != nil || loaded != "reloaded" {
+		t.Fatalf("Get with a loader inside the stale window = %v, %v", loaded, err)
+	}
+}
+
+func testCompareAndSwap(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	casAdaptor, ok := adaptor.(cache.CompareAndSwapContract)
+	if !ok {
+		t.Skip("adaptor does not implement cache.CompareAndSwapContract")
+	}
+	if err := casAdaptor.CompareAndSwap("key", 0, newEntry("first", 1)); err != nil {
+		t.Fatalf("CompareAndSwap on an absent key with version 0 returned %v", err)
+	}
+	if err := casAdaptor.CompareAndSwap("key", 0, newEntry("again", 2)); !errors.Is(err, cache.ErrVersionConflict) {
+		t.Fatalf("CompareAndSwap on a present key with version 0 returned %v, want cache.ErrVersionConflict", err)
+	}
+	if err := casAdaptor.CompareAndSwap("key", 7, newEntry("wrong", 2)); !errors.Is(err, cache.ErrVersionConflict) {
+		t.Fatalf("CompareAndSwap with a stale version returned %v, want cache.ErrVersionConflict", err)
+	}
+	if err := casAdaptor.CompareAndSwap("key", 1, newEntry("second", 2)); err != nil {
+		t.Fatalf("CompareAndSwap with the current version returned %v", err)
+	}
+	if got := mustGet(t, adaptor, "key"); got.Value != "second" || got.Version != 2 {
+		t.Fatalf("got (%#v, %d), want (\"second\", 2)", got.Value, got.Version)
+	}
+	assertNotFound(t, casAdaptor.CompareAndSwap("missing", 3, newEntry("x", 4)), "CompareAndSwap on a missing key")
+
+	// concurrent increments through CompareAndSwap must not lose an update
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrentWorker; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				for {
+					current, err := adaptor.Get("key")
+					if err != nil {
+						t.Errorf(`Get("key") returned %v`, err)
+						return
+					}
+					err = casAdaptor.CompareAndSwap("key", current.Version, newEntry(current.Value, current.Version+1))
+					if err == nil {
+						break
+					}
+					if !errors.Is(err, cache.ErrVersionConflict) {
+						t.Errorf("CompareAndSwap returned %v", err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := mustGet(t, adaptor, "key"); got.Version != 2+concurrentWorker*20 {
+		t.Errorf("Version after concurrent swaps = %d, want %d", got.Version, 2+concurrentWorker*20)
+	}
+}
+
+func testKeys(t *testing.T, adaptor cache.CacheAdaptorServiceContract) {
+	keysAdaptor, ok := adaptor.(cache.KeysContract)
+	if !ok {
+		t.Skip("adaptor does not implement cache.KeysContract")
+	}
+	want := []string{"a", "b", "c"}
+	for _, key := range want {
+		mustSet(t, adaptor, key, newEntry(key, 1))
+	}
+	if err := adaptor.Delete("b"); err != nil {
+		t.Fatalf(`Delete("b") returned %v`, err)
+	}
+	got, err := keysAdaptor.Keys()
+	if err != nil {
+		t.Fatalf("Keys returned %v", err)
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("Keys = %v, want [a c]", got)
+	}
+}
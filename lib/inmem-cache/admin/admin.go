@@ -0,0 +1,273 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownCache = errors.New("unknown cache")
+	ErrReadOnly     = errors.New("admin api is read-only")
+	ErrStatsOff     = errors.New("stats are disabled for this cache")
+	ErrAuthRequired = errors.New("admin writes need an authorizer, see WithAuth")
+	ErrBadToken     = errors.New("missing or invalid bearer token")
+)
+
+// Authorizer rejects a request by returning an error, the error message is sent back with 401
+type Authorizer func(r *http.Request) error
+
+// BearerToken accepts requests carrying "Authorization: Bearer <token>", an empty token rejects everything
+func BearerToken(token string) Authorizer {
+	return func(r *http.Request) error {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			return ErrBadToken
+		}
+		return nil
+	}
+}
+
+// Handler exposes registered caches over HTTP, mount it with http.StripPrefix when it
+// doesn't live at the root of the mux:
+//
+//	GET    /caches
+//	GET    /caches/{name}/keys/{key}
+//	PUT    /caches/{name}/keys/{key}             {"value": ..., "tags": [...]}
+//	DELETE /caches/{name}/keys/{key}
+//	POST   /caches/{name}/keys/{key}/soft-delete
+//	DELETE /caches/{name}/tags/{tag}
+//	DELETE /caches/{name}/prefixes/{prefix}
+//	GET    /caches/{name}/tags
+//	GET    /caches/{name}/stats
+//
+// The write endpoints answer 403 unless the handler has an Authorizer, so a handler mounted
+// without WithAuth can't be used to change a cache. With an Authorizer the reads go through it too.
+type Handler struct {
+	mux      *http.ServeMux
+	mu       sync.RWMutex
+	caches   map[string]*cache.Cache
+	readOnly bool
+	auth     Authorizer
+}
+
+type OptionalHandlerConfig func(h *Handler)
+
+func WithReadOnly() OptionalHandlerConfig {
+	return func(h *Handler) {
+		h.readOnly = true
+	}
+}
+
+func WithAuth(auth Authorizer) OptionalHandlerConfig {
+	return func(h *Handler) {
+		h.auth = auth
+	}
+}
+
+type setRequest struct {
+	Value interface{} `json:"value"`
+	Tags  []string    `json:"tags"`
+}
+
+type valueResponse struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewHandler(optionalHandlerConfigs ...OptionalHandlerConfig) *Handler {
+	h := &Handler{
+		mux:    http.NewServeMux(),
+		caches: make(map[string]*cache.Cache),
+	}
+	for _, option := range optionalHandlerConfigs {
+		option(h)
+	}
+	h.mux.HandleFunc("GET /caches", h.authorize(h.listCaches))
+	h.mux.HandleFunc("GET /caches/{name}/keys/{key}", h.read(h.getKey))
+	h.mux.HandleFunc("PUT /caches/{name}/keys/{key}", h.write(h.setKey))
+	h.mux.HandleFunc("DELETE /caches/{name}/keys/{key}", h.write(h.deleteKey))
+	h.mux.HandleFunc("POST /caches/{name}/keys/{key}/soft-delete", h.write(h.softDeleteKey))
+	h.mux.HandleFunc("DELETE /caches/{name}/tags/{tag}", h.write(h.deleteTag))
+	h.mux.HandleFunc("DELETE /caches/{name}/prefixes/{prefix}", h.write(h.deletePrefix))
+	h.mux.HandleFunc("GET /caches/{name}/tags", h.read(h.listTags))
+	h.mux.HandleFunc("GET /caches/{name}/stats", h.read(h.stats))
+	return h
+}
+
+func (h *Handler) Register(name string, c *cache.Cache) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.caches[name] = c
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// authorize runs the Authorizer in front of next when the handler has one
+func (h *Handler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.auth != nil {
+			if err := h.auth(r); err != nil {
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+type cacheHandlerFunc func(w http.ResponseWriter, r *http.Request, c *cache.Cache)
+
+func (h *Handler) withCache(next cacheHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.mu.RLock()
+		c, ok := h.caches[r.PathValue("name")]
+		h.mu.RUnlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, ErrUnknownCache)
+			return
+		}
+		next(w, r, c)
+	}
+}
+
+func (h *Handler) read(next cacheHandlerFunc) http.HandlerFunc {
+	return h.authorize(h.withCache(next))
+}
+
+func (h *Handler) write(next cacheHandlerFunc) http.HandlerFunc {
+	return h.authorize(h.withCache(func(w http.ResponseWriter, r *http.Request, c *cache.Cache) {
+		if h.readOnly {
+			writeError(w, http.StatusForbidden, ErrReadOnly)
+			return
+		}
+		if h.auth == nil {
+			writeError(w, http.StatusForbidden, ErrAuthRequired)
+			return
+		}
+		next(w, r, c)
+	}))
+}
+
+func (h *Handler) listCaches(w http.ResponseWriter, _ *http.Request) {
+	h.mu.RLock()
+	names := make([]string, 0, len(h.caches))
+	for name := range h.caches {
+		names = append(names, name)
+	}
+	h.mu.RUnlock()
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+func (h *Handler) getKey(w http.ResponseWriter, r *http.Request, c *cache.Cache) {
+	key := r.PathValue("key")
+	// Peek so inspecting a key doesn't show up in the stats or evict it
+	val, err := c.Peek(key)
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, valueResponse{Key: key, Value: val})
+}
+
+func (h *Handler) setKey(w http.ResponseWriter, r *http.Request, c *cache.Cache) {
+	var body setRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	key := r.PathValue("key")
+	if err := c.Set(key, body.Value, body.Tags...); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, valueResponse{Key: key, Value: body.Value})
+}
+
+func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request, c *cache.Cache) {
+	h.delete(w, c, cache.DeleteWithKeys([]string{r.PathValue("key")}))
+}
+
+func (h *Handler) deleteTag(w http.ResponseWriter, r *http.Request, c *cache.Cache) {
+	h.delete(w, c, cache.DeleteWithTags([]string{r.PathValue("tag")}))
+}
+
+func (h *Handler) deletePrefix(w http.ResponseWriter, r *http.Request, c *cache.Cache) {
+	h.delete(w, c, cache.DeleteWithPrefix([]string{r.PathValue("prefix")}))
+}
+
+func (h *Handler) delete(w http.ResponseWriter, c *cache.Cache, deleteOption cache.DeleteOptions) {
+	deletionRes, err := c.Delete(deleteOption)
+	if deletionRes == nil {
+		writeCacheError(w, err)
+		return
+	}
+	failed := make([]string, 0, len(deletionRes.Failed))
+	for _, failure := range deletionRes.Failed {
+		failed = append(failed, failure.Error())
+	}
+	status := http.StatusOK
+	if len(deletionRes.Success) == 0 && len(failed) > 0 {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, map[string][]string{
+		"deleted": deletionRes.Success,
+		"failed":  failed,
+	})
+}
+
+func (h *Handler) softDeleteKey(w http.ResponseWriter, r *http.Request, c *cache.Cache) {
+	if err := c.SoftDelete(r.PathValue("key")); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listTags(w http.ResponseWriter, _ *http.Request, c *cache.Cache) {
+	writeJSON(w, http.StatusOK, c.GetTags())
+}
+
+func (h *Handler) stats(w http.ResponseWriter, _ *http.Request, c *cache.Cache) {
+	stats := c.GetStats()
+	if stats == nil {
+		writeError(w, http.StatusNotFound, ErrStatsOff)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats.Snapshot())
+}
+
+func writeCacheError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cache.ErrEntryNotFound), errors.Is(err, cache.ErrStaleResponse):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, cache.ErrInvalidDeletionArgs):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, cache.ErrPrefixDeletionUnsupported):
+		writeError(w, http.StatusNotImplemented, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
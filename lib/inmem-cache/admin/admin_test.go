@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"encoding/json"
+	cache "inmem/lib/inmem-cache"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHandler(t *testing.T, optionalHandlerConfigs ...OptionalHandlerConfig) (*Handler, *cache.Cache) {
+	t.Helper()
+	c := cache.GetCache(map_cache.CreateMapCache(), time.Minute, true)
+	t.Cleanup(c.Close)
+	h := NewHandler(optionalHandlerConfigs...)
+	h.Register("todo", c)
+	return h, c
+}
+
+func serve(h http.Handler, method string, path string, body string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestWritesNeedAnAuthorizer(t *testing.T) {
+	h, c := newTestHandler(t)
+	c.Set("key", "value")
+
+	if w := serve(h, http.MethodGet, "/caches/todo/keys/key", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET = %d, want reads to stay open", w.Code)
+	}
+	for _, write := range []struct{ method, path, body string }{
+		{http.MethodPut, "/caches/todo/keys/key", `{"value":"changed"}`},
+		{http.MethodDelete, "/caches/todo/keys/key", ""},
+		{http.MethodPost, "/caches/todo/keys/key/soft-delete", ""},
+		{http.MethodDelete, "/caches/todo/prefixes/k", ""},
+	} {
+		if w := serve(h, write.method, write.path, write.body, ""); w.Code != http.StatusForbidden {
+			t.Fatalf("%s %s without WithAuth = %d, want 403", write.method, write.path, w.Code)
+		}
+	}
+	if val, err := c.Get("key"); err != nil || val != "value" {
+		t.Fatalf("Get = %v, %v, want the value untouched", val, err)
+	}
+}
+
+func TestBearerTokenAuth(t *testing.T) {
+	h, c := newTestHandler(t, WithAuth(BearerToken("secret")))
+
+	if w := serve(h, http.MethodPut, "/caches/todo/keys/key", `{"value":"v"}`, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("PUT with a wrong token = %d, want 401", w.Code)
+	}
+	if w := serve(h, http.MethodPut, "/caches/todo/keys/key", `{"value":"v","tags":["t"]}`, "secret"); w.Code != http.StatusOK {
+		t.Fatalf("PUT with the token = %d: %s", w.Code, w.Body)
+	}
+	if val, err := c.Get("key"); err != nil || val != "v" {
+		t.Fatalf("Get = %v, %v, want the value set through the api", val, err)
+	}
+	w := serve(h, http.MethodDelete, "/caches/todo/tags/t", "", "secret")
+	var deleted map[string][]string
+	json.NewDecoder(w.Body).Decode(&deleted)
+	if w.Code != http.StatusOK || len(deleted["deleted"]) != 1 || deleted["deleted"][0] != "key" {
+		t.Fatalf("DELETE tag = %d %v, want key deleted", w.Code, deleted)
+	}
+	for _, path := range []string{"/caches", "/caches/todo/keys/key", "/caches/todo/tags", "/caches/todo/stats"} {
+		if w := serve(h, http.MethodGet, path, "", ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("GET %s without the token = %d, want 401", path, w.Code)
+		}
+	}
+	if BearerToken("")(httptest.NewRequest(http.MethodGet, "/", nil)) == nil {
+		t.Fatal("an empty token accepted a request without credentials")
+	}
+}
+
+func TestReadOnlyRejectsWritesEvenWithAuth(t *testing.T) {
+	h, _ := newTestHandler(t, WithReadOnly(), WithAuth(BearerToken("secret")))
+	if w := serve(h, http.MethodPut, "/caches/todo/keys/key", `{"value":"v"}`, "secret"); w.Code != http.StatusForbidden {
+		t.Fatalf("PUT on a read-only handler = %d, want 403", w.Code)
+	}
+}
+
+func TestGetKeyHasNoSideEffects(t *testing.T) {
+	h, c := newTestHandler(t)
+	c.Set("key", "value")
+	before := c.GetStats().Snapshot()
+
+	w := serve(h, http.MethodGet, "/caches/todo/keys/key", "", "")
+	var got valueResponse
+	json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || got.Value != "value" {
+		t.Fatalf("GET = %d %+v, want the value", w.Code, got)
+	}
+	if w := serve(h, http.MethodGet, "/caches/todo/keys/missing", "", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("GET of a missing key = %d, want 404", w.Code)
+	}
+	if after := c.GetStats().Snapshot(); after.Hits != before.Hits || after.Misses != before.Misses {
+		t.Fatalf("stats went from %+v to %+v, want the admin reads left out", before, after)
+	}
+}
+
+func TestUnknownCache(t *testing.T) {
+	h, _ := newTestHandler(t)
+	if w := serve(h, http.MethodGet, "/caches/missing/stats", "", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("GET stats of an unknown cache = %d, want 404", w.Code)
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"fmt"
 	"github.com/allegro/bigcache/v3"
 	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/clock"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 func Serialize(cacheEntry *cache.CacheEntry) ([]byte, error) {
@@ -25,25 +29,76 @@ func Deserialize(cacheEntrySerialized []byte) (*cache.CacheEntry, error) {
 	return &cacheEntry, nil
 }
 
+// storedEntry is what the adaptor writes to bigcache, StoredAt comes from the adaptor clock
+// so the life window doesn't depend on bigcache's wall clock timestamps.
+// Deserialize ignores StoredAt, entries written by older versions never expire here.
+type storedEntry struct {
+	cache.CacheEntry
+	StoredAt time.Time
+}
+
 type BigCacheAdapter struct {
-	cache *bigcache.BigCache
+	cache      *bigcache.BigCache
+	shardLocks []sync.Mutex
+	shardMask  uint64
+	lifeWindow time.Duration
+	clock      atomic.Pointer[clock.Clock]
+	onEviction atomic.Pointer[func(key string, reason cache.EvictReason)]
+}
+
+// UseClock replaces the clock the life window is measured with, GetCache passes the cache clock
+func (bigCache *BigCacheAdapter) UseClock(clock clock.Clock) {
+	bigCache.clock.Store(&clock)
 }
 
 func (bigCache *BigCacheAdapter) Get(key string) (*cache.CacheEntry, error) {
-	value, err := bigCache.cache.Get(key)
-	if err != nil {
+	cacheEntry, expired, err := bigCache.get(key)
+	if err != nil || !expired {
+		return cacheEntry, err
+	}
+	lock := bigCache.shardLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	// a Set may have replaced the entry since it was read
+	if cacheEntry, expired, err = bigCache.get(key); err != nil || !expired {
+		return cacheEntry, err
+	}
+	if err = bigCache.cache.Delete(key); err != nil {
 		return nil, getError(err)
 	}
-	cacheEntry, err := Deserialize(value)
+	if onEviction := bigCache.onEviction.Load(); onEviction != nil {
+		(*onEviction)(key, cache.EvictExpired)
+	}
+	return nil, cache.ErrEntryNotFound
+}
+
+// get reads the entry and reports whether it outlived the life window, the caller removes it
+func (bigCache *BigCacheAdapter) get(key string) (*cache.CacheEntry, bool, error) {
+	value, err := bigCache.cache.Get(key)
 	if err != nil {
-		return nil, cache.WrapError(
+		return nil, false, getError(err)
+	}
+	var stored storedEntry
+	if err = json.Unmarshal(value, &stored); err != nil {
+		return nil, false, cache.WrapError(
 			fmt.Sprintf("failed to unmarshal cache entry key : %s value : %s ", key, string(value)), err)
 	}
-	return cacheEntry, nil
+	expired := !stored.StoredAt.IsZero() && (*bigCache.clock.Load()).Since(stored.StoredAt) > bigCache.lifeWindow
+	return &stored.CacheEntry, expired, nil
 }
 
 func (bigCache *BigCacheAdapter) Set(key string, cacheEntry *cache.CacheEntry) error {
-	cacheValue, err := Serialize(cacheEntry)
+	lock := bigCache.shardLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	return bigCache.set(key, cacheEntry)
+}
+
+func (bigCache *BigCacheAdapter) set(key string, cacheEntry *cache.CacheEntry) error {
+	if cacheEntry == nil {
+		return cache.ErrInvalidCacheEntry
+	}
+	cacheValue, err := json.Marshal(storedEntry{CacheEntry: *cacheEntry, StoredAt: (*bigCache.clock.Load()).Now()})
 	if err != nil {
 		return cache.WrapError(fmt.Sprintf("failed to marshal cache entry key : %s", key), err)
 	}
@@ -55,6 +110,9 @@ func (bigCache *BigCacheAdapter) Set(key string, cacheEntry *cache.CacheEntry) e
 }
 
 func (bigCache *BigCacheAdapter) Delete(key string) error {
+	lock := bigCache.shardLock(key)
+	lock.Lock()
+	defer lock.Unlock()
 	err := bigCache.cache.Delete(key)
 	if err != nil {
 		return getError(err)
@@ -62,6 +120,70 @@ func (bigCache *BigCacheAdapter) Delete(key string) error {
 	return nil
 }
 
+// CompareAndSwap holds the lock of the key's shard across the read and the write,
+// Set and Delete take the same lock so nothing can slip in between
+func (bigCache *BigCacheAdapter) CompareAndSwap(key string, expectedVersion uint64, cacheEntry *cache.CacheEntry) error {
+	lock := bigCache.shardLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	current, expired, err := bigCache.get(key)
+	if expired {
+		current, err = nil, cache.ErrEntryNotFound
+	}
+	switch {
+	case errors.Is(err, cache.ErrEntryNotFound):
+		if expectedVersion != 0 {
+			return cache.ErrEntryNotFound
+		}
+	case err != nil:
+		return err
+	case current.Version != expectedVersion:
+		return cache.ErrVersionConflict
+	}
+	return bigCache.set(key, cacheEntry)
+}
+
+// shardLock picks the lock the same way bigcache picks the shard, fnv64a of the key masked by the shard count
+func (bigCache *BigCacheAdapter) shardLock(key string) *sync.Mutex {
+	var hash uint64 = 14695981039346656037
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= 1099511628211
+	}
+	return &bigCache.shardLocks[hash&bigCache.shardMask]
+}
+
+func (bigCache *BigCacheAdapter) Keys() ([]string, error) {
+	keys := make([]string, 0, bigCache.cache.Len())
+	iterator := bigCache.cache.Iterator()
+	for iterator.SetNext() {
+		entryInfo, err := iterator.Value()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, entryInfo.Key())
+	}
+	return keys, nil
+}
+
+func (bigCache *BigCacheAdapter) NotifyEvictions(onEviction func(key string, reason cache.EvictReason)) {
+	bigCache.onEviction.Store(&onEviction)
+}
+
+// onRemoveWithReason is registered on the bigcache config by CreateBigCache, it runs under the shard lock
+func (bigCache *BigCacheAdapter) onRemoveWithReason(key string, _ []byte, reason bigcache.RemoveReason) {
+	onEviction := bigCache.onEviction.Load()
+	if onEviction == nil {
+		return
+	}
+	switch reason {
+	case bigcache.Expired:
+		(*onEviction)(key, cache.EvictExpired)
+	case bigcache.NoSpace:
+		(*onEviction)(key, cache.EvictCapacity)
+	}
+}
+
 func getError(err error) error {
 	if errors.Is(err, bigcache.ErrEntryNotFound) {
 		return cache.ErrEntryNotFound
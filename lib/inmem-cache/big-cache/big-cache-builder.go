@@ -3,9 +3,15 @@ package big_cache
 import (
 	"context"
 	"github.com/allegro/bigcache/v3"
+	"inmem/lib/inmem-cache/clock"
+	"sync"
 	"time"
 )
 
+// lifeWindowSlack is how far bigcache's own life window and cleanup trail the adaptor's, bigcache
+// timestamps have second resolution so it only drops entries the adaptor already treats as expired
+const lifeWindowSlack = time.Second
+
 type BigCacheConfig struct {
 	shards           int
 	lifeWindow       time.Duration
@@ -47,11 +53,23 @@ func CreateBigCache(optionalBigCacheConfigs ...OptionalBigCacheConfig) *BigCache
 		Verbose:          false,
 		HardMaxCacheSize: 1000,
 	}
+	adapter := &BigCacheAdapter{}
+	// deletes are reported by the cache itself, only forward the removals bigcache decides on
+	cfg.OnRemoveWithReason = adapter.onRemoveWithReason
+	cfg = cfg.OnRemoveFilterSet(bigcache.Expired, bigcache.NoSpace)
 	for _, option := range optionalBigCacheConfigs {
 		option(&cfg)
 	}
-	bigCache, _ := bigcache.New(context.Background(), cfg)
-	return &BigCacheAdapter{
-		cache: bigCache,
+	// the adaptor enforces the life window on its own clock, bigcache's wall clock one trails it
+	// by lifeWindowSlack and only cleans up the entries nobody read after they expired
+	adapter.lifeWindow = cfg.LifeWindow
+	adapter.UseClock(clock.Real())
+	cfg.LifeWindow += lifeWindowSlack
+	if cfg.CleanWindow <= 0 {
+		cfg.CleanWindow = cfg.LifeWindow
 	}
+	adapter.cache, _ = bigcache.New(context.Background(), cfg)
+	adapter.shardLocks = make([]sync.Mutex, cfg.Shards)
+	adapter.shardMask = uint64(cfg.Shards - 1)
+	return adapter
 }
@@ -0,0 +1,74 @@
+package big_cache
+
+import (
+	"errors"
+	"github.com/allegro/bigcache/v3"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/clock/fakeclock"
+	"testing"
+	"time"
+)
+
+func TestLifeWindowFollowsTheCacheClock(t *testing.T) {
+	clk := fakeclock.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	adapter := CreateBigCache(func(b *bigcache.Config) { b.LifeWindow = time.Minute })
+	c := cache.GetCache(adapter, time.Hour, true, cache.WithClock(clk))
+	t.Cleanup(c.Close)
+	evictions := make(chan cache.CacheEvent, 1)
+	c.OnEvict(func(event cache.CacheEvent) { evictions <- event })
+
+	c.Set("key", "value")
+	clk.Advance(59 * time.Second)
+	if _, err := adapter.Get("key"); err != nil {
+		t.Fatalf("Get inside the life window = %v", err)
+	}
+	clk.Advance(2 * time.Second)
+	if _, err := adapter.Get("key"); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("Get past the life window = %v, want ErrEntryNotFound", err)
+	}
+	select {
+	case event := <-evictions:
+		if event.Key != "key" || event.Reason != cache.EvictExpired {
+			t.Fatalf("eviction = %+v, want key expired", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no eviction reported for the expired entry")
+	}
+}
+
+func TestUnreadEntriesAreCleanedUp(t *testing.T) {
+	adapter := CreateBigCache(func(b *bigcache.Config) {
+		b.LifeWindow = time.Second
+		b.CleanWindow = time.Second
+	})
+	c := cache.GetCache(adapter, time.Hour, true)
+	t.Cleanup(c.Close)
+	evictions := make(chan cache.CacheEvent, 1)
+	c.OnEvict(func(event cache.CacheEvent) { evictions <- event })
+
+	c.Set("key", "value")
+	// nothing reads the key, so only bigcache's cleanup after the life window and its slack can drop it
+	select {
+	case event := <-evictions:
+		if event.Key != "key" || event.Reason != cache.EvictExpired {
+			t.Fatalf("eviction = %+v, want key expired", event)
+		}
+	case <-time.After(time.Second + lifeWindowSlack + 3*time.Second):
+		t.Fatal("bigcache never cleaned up the expired entry")
+	}
+}
+
+func TestCompareAndSwapTreatsExpiredEntriesAsAbsent(t *testing.T) {
+	clk := fakeclock.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	adapter := CreateBigCache(func(b *bigcache.Config) { b.LifeWindow = time.Minute })
+	adapter.UseClock(clk)
+	adapter.Set("key", &cache.CacheEntry{Value: "old", Version: 1})
+	clk.Advance(2 * time.Minute)
+
+	if err := adapter.CompareAndSwap("key", 1, &cache.CacheEntry{Value: "new", Version: 2}); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("CompareAndSwap on an expired entry = %v, want ErrEntryNotFound", err)
+	}
+	if err := adapter.CompareAndSwap("key", 0, &cache.CacheEntry{Value: "new", Version: 2}); err != nil {
+		t.Fatal(err)
+	}
+}
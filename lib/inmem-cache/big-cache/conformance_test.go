@@ -0,0 +1,13 @@
+package big_cache
+
+import (
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/adaptortest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	adaptortest.Run(t, func(t *testing.T) cache.CacheAdaptorServiceContract {
+		return CreateBigCache()
+	})
+}
@@ -1,10 +1,11 @@
 package inmem_cache
 
 import (
-	"encoding/json"
 	"errors"
 	"golang.org/x/sync/singleflight"
+	"inmem/lib/inmem-cache/clock"
 	"inmem/lib/logger"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,8 +20,20 @@ type Cache struct {
 	tagsMutex       sync.Mutex
 	deleteThreshold atomic.Int32
 	stats           *CacheStats
+	events          *EventDispatcher
+	invalidations   invalidationListeners
+	versions        atomic.Uint64
+	keyLocks        keyLocks
+	log             *logger.Logger
+	name            string
+	readiness       readiness
+	loadFailures    atomic.Int32
+	probes          atomic.Uint64
+	clock           clock.Clock
 }
 
+type OptionalCacheConfig func(c *Cache)
+
 type CacheOptions func(c *cacheOptionsConfig)
 
 type DeleteOptions func(d *deleteOptionsConfig)
@@ -29,6 +42,8 @@ type cacheOptionsConfig struct {
 	loader           loaderContract
 	staleResponseTtl time.Duration
 	bypass           bool
+	sliding          bool
+	slidingWindow    time.Duration
 }
 
 func WithLoader(loader loaderContract) CacheOptions {
@@ -48,8 +63,11 @@ func WithStaleResponse(staleTtl time.Duration, options ...CacheOptions) CacheOpt
 }
 
 type deleteOptionsConfig struct {
-	tags []string
-	keys []string
+	tags     []string
+	keys     []string
+	prefixes []string
+	reason   EvictReason
+	origin   string
 }
 
 type DeletionResult struct {
@@ -69,6 +87,27 @@ func DeleteWithTags(tags []string) DeleteOptions {
 	}
 }
 
+// DeleteWithPrefix removes every key starting with one of the prefixes, the adaptor has to implement KeysContract
+func DeleteWithPrefix(prefixes []string) DeleteOptions {
+	return func(d *deleteOptionsConfig) {
+		d.prefixes = prefixes
+	}
+}
+
+// DeleteFromOrigin marks the deletion as applied on behalf of another node, invalidation
+// listeners receive the origin so they don't broadcast it again
+func DeleteFromOrigin(origin string) DeleteOptions {
+	return func(d *deleteOptionsConfig) {
+		d.origin = origin
+	}
+}
+
+func deleteWithReason(reason EvictReason) DeleteOptions {
+	return func(d *deleteOptionsConfig) {
+		d.reason = reason
+	}
+}
+
 func getDeleteOptionConfig(delOpts []DeleteOptions) deleteOptionsConfig {
 	var opts = deleteOptionsConfig{}
 	for _, option := range delOpts {
@@ -87,28 +126,77 @@ func WithByPass(options ...CacheOptions) CacheOptions {
 	}
 }
 
-func GetCache(cacheAdaptor CacheAdaptorServiceContract, ttl time.Duration, stats bool) *Cache {
+func WithEventBuffer(bufferSize int, workers int) OptionalCacheConfig {
+	return func(c *Cache) {
+		c.events = NewEventDispatcher(bufferSize, workers)
+	}
+}
+
+// WithName binds cache=name to the entries the cache logs
+func WithName(name string) OptionalCacheConfig {
+	return func(c *Cache) {
+		c.name = name
+	}
+}
+
+// WithLogger replaces the cache logger, the default is logger.Named("cache")
+func WithLogger(log *logger.Logger) OptionalCacheConfig {
+	return func(c *Cache) {
+		c.log = log
+	}
+}
+
+// WithClock replaces the wall clock used for expiry, stale windows, load times and stats
+func WithClock(clock clock.Clock) OptionalCacheConfig {
+	return func(c *Cache) {
+		c.clock = clock
+	}
+}
+
+func GetCache(cacheAdaptor CacheAdaptorServiceContract, ttl time.Duration, stats bool, optionalCacheConfigs ...OptionalCacheConfig) *Cache {
 	newCacheWithDefaultConfig := &Cache{
 		cacheAdaptor: cacheAdaptor,
 		ttl:          ttl,
 		tags:         make(map[string][]string),
+		events:       NewEventDispatcher(defaultEventBufferSize, defaultEventWorkers),
+		clock:        clock.Real(),
+	}
+	for _, option := range optionalCacheConfigs {
+		option(newCacheWithDefaultConfig)
+	}
+	newCacheWithDefaultConfig.events.clock = newCacheWithDefaultConfig.clock
+	if newCacheWithDefaultConfig.log == nil {
+		newCacheWithDefaultConfig.log = logger.Named("cache")
 	}
+	if newCacheWithDefaultConfig.name != "" {
+		newCacheWithDefaultConfig.log = newCacheWithDefaultConfig.log.With(logger.String("cache", newCacheWithDefaultConfig.name))
+	}
+	newCacheWithDefaultConfig.events.log = newCacheWithDefaultConfig.log
 	if stats {
-		newCacheWithDefaultConfig.stats = InitStats()
+		newCacheWithDefaultConfig.stats = initStats(newCacheWithDefaultConfig.clock, newCacheWithDefaultConfig.log)
+	}
+	if notifier, ok := cacheAdaptor.(EvictionNotifier); ok {
+		notifier.NotifyEvictions(newCacheWithDefaultConfig.onAdaptorEviction)
+	}
+	if clockAware, ok := cacheAdaptor.(ClockContract); ok {
+		clockAware.UseClock(newCacheWithDefaultConfig.clock)
 	}
 	return newCacheWithDefaultConfig
 }
 
 type CacheEntry struct {
-	Value interface{}
-	TTL   time.Duration
+	Value     interface{}
+	ExpiresAt time.Time
+	Version   uint64
 }
 
-func (ce *CacheEntry) isInValidEntry(buffer time.Duration) bool {
-	if ce.TTL <= time.Duration(time.Now().Add(-buffer).UnixNano()) {
-		return true
-	}
-	return false
+// ExpiresIn returns the remaining lifetime at now, negative once the entry is stale
+func (ce *CacheEntry) ExpiresIn(now time.Time) time.Duration {
+	return ce.ExpiresAt.Sub(now)
+}
+
+func (ce *CacheEntry) isInValidEntry(now time.Time, buffer time.Duration) bool {
+	return !ce.ExpiresAt.After(now.Add(-buffer))
 }
 
 func getCacheOptions(options []CacheOptions) *cacheOptionsConfig {
@@ -122,10 +210,7 @@ func (c *Cache) Get(key string, options ...CacheOptions) (res interface{}, err e
 	defer func() {
 		if err != nil {
 			err = cacheError(GET, key, err)
-			logger.Dispatch(logger.ERROR, logger.WithEntry().
-				WithMessage(err.Error()).
-				WithField("key", key).
-				WithField("op", "get"))
+			c.logError(GET, key, err)
 		}
 	}()
 	optionalConfig := getCacheOptions(options)
@@ -137,6 +222,7 @@ func (c *Cache) Get(key string, options ...CacheOptions) (res interface{}, err e
 	}
 	val, err := c.cacheAdaptor.Get(key)
 	if err != nil {
+		c.events.Emit(CacheEvent{Type: EventMiss, Key: key})
 		if !errors.Is(err, ErrEntryNotFound) {
 			c.stats.Miss()
 			return nil, err
@@ -146,53 +232,73 @@ func (c *Cache) Get(key string, options ...CacheOptions) (res interface{}, err e
 			return nil, ErrEntryNotFound
 		}
 		return c.loadAndSet(key, optionalConfig.loader)
-	} else if val.isInValidEntry(0) {
+	} else if now := c.clock.Now(); val.isInValidEntry(now, 0) {
 		c.stats.Stale()
-		if optionalConfig.loader == nil || val.isInValidEntry(optionalConfig.staleResponseTtl) {
+		c.events.Emit(CacheEvent{Type: EventStale, Key: key, Value: val.Value})
+		if optionalConfig.loader == nil || val.isInValidEntry(now, optionalConfig.staleResponseTtl) {
 			c.stats.Evict()
-			c.Delete(DeleteWithKeys([]string{key}))
+			c.Delete(DeleteWithKeys([]string{key}), deleteWithReason(EvictExpired))
 			return nil, ErrStaleResponse
 		}
 		// here since the serve stale is set we should return the stale response but also load the value in background
 		return c.loadAndSet(key, optionalConfig.loader)
 	}
 	c.stats.Hit()
+	c.events.Emit(CacheEvent{Type: EventHit, Key: key, Value: val.Value})
+	if optionalConfig.sliding {
+		c.slide(key, val, optionalConfig.slidingWindow)
+	}
 	return val.Value, nil
 }
 
 func (c *Cache) loadAndSet(key string, loader loaderContract) (interface{}, error) {
 	newVal, err := c.load(key, loader)
-	if err == nil {
-		return nil, ErrLoaderFailed
+	if err != nil {
+		return nil, errors.Join(ErrLoaderFailed, err)
 	}
-	c.Set(key, newVal)
+	c.set(key, newVal, c.ttl, true)
 	return newVal, nil
 }
 func (c *Cache) load(key string, loader loaderContract) (interface{}, error) {
-	startTime := time.Now()
+	startTime := c.clock.Now()
 	//Only fetch a key once; if already being fetched, block other goroutines until the fetch completes.
 	v, err, _ := c.loaderGroup.Do(key, func() (interface{}, error) {
 		val, err := loader(key)
 		c.stats.LoadCount()
+		if err != nil {
+			c.loadFailures.Add(1)
+			c.events.Emit(CacheEvent{Type: EventLoadError, Key: key, Err: err, Duration: c.clock.Since(startTime)})
+		} else {
+			c.loadFailures.Store(0)
+			c.events.Emit(CacheEvent{Type: EventLoad, Key: key, Value: val, Duration: c.clock.Since(startTime)})
+		}
 		return val, err
 	})
-	c.stats.LoadTime(time.Since(startTime))
+	c.stats.LoadTime(c.clock.Since(startTime))
 	return v, err
 }
 
 func (c *Cache) Set(key string, val any, keyTags ...string) (err error) {
+	return c.SetWithTTL(key, val, c.ttl, keyTags...)
+}
+
+// SetWithTTL behaves like Set but overrides the cache wide ttl for this entry
+func (c *Cache) SetWithTTL(key string, val any, ttl time.Duration, keyTags ...string) (err error) {
+	return c.set(key, val, ttl, false, keyTags...)
+}
+
+// set stores val, loaded marks the EventSet of a loader result so listeners can tell it from a caller's write
+func (c *Cache) set(key string, val any, ttl time.Duration, loaded bool, keyTags ...string) (err error) {
 	defer func() {
 		if err != nil {
 			err = cacheError(SET, key, err)
-			logger.Dispatch(logger.ERROR, logger.WithEntry().
-				WithMessage(err.Error()).
-				WithField("key", key).
-				WithField("op", "set"))
+			c.logError(SET, key, err)
 		}
 	}()
-	err = c.setKeyValueWithCustomTtl(key, val, c.ttl)
+	err = c.setKeyValueWithCustomTtl(key, val, ttl)
 	if err == nil {
 		c.stats.EntriesCount()
+		c.events.Emit(CacheEvent{Type: EventSet, Key: key, Value: val, Loaded: loaded})
 		for _, tag := range keyTags {
 			c.tagsMutex.Lock()
 			if c.tags[tag] == nil {
@@ -208,11 +314,10 @@ func (c *Cache) Delete(deleteOpts ...DeleteOptions) (deletionRes *DeletionResult
 	defer func() {
 		if err != nil {
 			err = cacheError(DELETE, "", err)
-			temp, _ := json.Marshal(deletionRes)
-			logger.Dispatch(logger.ERROR, logger.WithEntry().
-				WithMessage(err.Error()).
-				WithField("keys", string(temp)).
-				WithField("op", "delete"))
+			// deleting an absent key is an expected outcome, only real failures are logged
+			if !onlyNotFound(deletionRes) {
+				c.logError(DELETE, "", err, logger.Object("keys", deletionRes))
+			}
 		}
 	}()
 	deleteConfig := getDeleteOptionConfig(deleteOpts)
@@ -222,16 +327,33 @@ func (c *Cache) Delete(deleteOpts ...DeleteOptions) (deletionRes *DeletionResult
 		Failed:  []error{},
 		Success: []string{},
 	}
+	reason := EvictDeleted
+	invalidation := Invalidation{Origin: deleteConfig.origin}
 	if len(deleteConfig.keys) > 0 {
 		keys = deleteConfig.keys
 		c.deleteThreshold.Add(1)
+		invalidation.Kind, invalidation.Values = InvalidateKeys, deleteConfig.keys
 	} else if len(deleteConfig.tags) > 0 {
 		keys = c.getKeysByTag(deleteConfig.tags)
+		reason = EvictTag
+		invalidation.Kind, invalidation.Values = InvalidateTags, deleteConfig.tags
+	} else if len(deleteConfig.prefixes) > 0 {
+		keys, err = c.getKeysByPrefix(deleteConfig.prefixes)
+		if err != nil {
+			return nil, err
+		}
+		invalidation.Kind, invalidation.Values = InvalidatePrefixes, deleteConfig.prefixes
 	} else {
 		return nil, ErrInvalidDeletionArgs
 	}
+	if deleteConfig.reason != "" {
+		reason = deleteConfig.reason
+	} else {
+		// expiry is decided locally by every node, only caller initiated deletes are invalidations
+		defer c.invalidations.notify(invalidation)
+	}
 	for _, key := range keys {
-		err = c.cacheAdaptor.Delete(key)
+		err = c.delete(key, reason)
 		if err != nil {
 			c.stats.DeleteMiss()
 			cacheError := &CacheError{
@@ -252,31 +374,81 @@ func (c *Cache) Delete(deleteOpts ...DeleteOptions) (deletionRes *DeletionResult
 			c.deleteThreshold.Store(0)
 			return nil, nil
 		})
-		if err != nil {
-			return nil, err
-		}
 	}
 	return deletionRes, deletionError
 }
-func (c *Cache) SoftDelete(key string) (err error) {
+func onlyNotFound(deletionRes *DeletionResult) bool {
+	if deletionRes == nil || len(deletionRes.Failed) == 0 {
+		return false
+	}
+	for _, err := range deletionRes.Failed {
+		if !errors.Is(err, ErrEntryNotFound) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cache) delete(key string, reason EvictReason) error {
+	err := c.cacheAdaptor.Delete(key)
+	if err == nil {
+		c.events.Emit(CacheEvent{Type: EventEvict, Key: key, Reason: reason})
+	}
+	return err
+}
+
+func (c *Cache) SoftDelete(key string, deleteOpts ...DeleteOptions) (err error) {
 	defer func() {
 		if err != nil {
-			err = cacheError(SOFTDELETE, "", err)
+			err = cacheError(SOFTDELETE, key, err)
 		}
 	}()
-	val, err := c.Get(key)
-	if err != nil {
-		if errors.Is(err, ErrEntryNotFound) {
-			return ErrEntryNotFound
+	deleteConfig := getDeleteOptionConfig(deleteOpts)
+	_, err = c.update(key, 0, func(old any, found bool) (any, error) {
+		if !found {
+			return nil, ErrEntryNotFound
 		}
+		return old, nil
+	})
+	if err != nil {
 		return err
 	}
-	return c.setKeyValueWithCustomTtl(key, val, 0)
+	c.invalidations.notify(Invalidation{
+		Kind:   InvalidateKeys,
+		Values: []string{key},
+		Soft:   true,
+		Origin: deleteConfig.origin,
+	})
+	return nil
+}
+
+// Exists reports whether key has a live entry, it reads the adaptor directly so it doesn't count as a hit or a miss
+func (c *Cache) Exists(key string) bool {
+	val, err := c.cacheAdaptor.Get(key)
+	return err == nil && !val.isInValidEntry(c.clock.Now(), 0)
+}
+
+// Peek returns the live value of key without counting a hit or a miss, logging or evicting a stale entry
+func (c *Cache) Peek(key string) (any, error) {
+	val, _, ok := c.read(key)
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+	return val, nil
 }
 
 func (c *Cache) setKeyValueWithCustomTtl(key string, value interface{}, ttl time.Duration) error {
-	cacheEntry := &CacheEntry{Value: value, TTL: time.Duration(time.Now().Add(ttl).UnixNano())}
-	return c.cacheAdaptor.Set(key, cacheEntry)
+	return c.cacheAdaptor.Set(key, c.newEntry(value, ttl))
+}
+
+// newEntry stamps every write with a new version, versions only grow so a compare-and-swap
+// against an old version can never succeed
+func (c *Cache) newEntry(value interface{}, ttl time.Duration) *CacheEntry {
+	return &CacheEntry{
+		Value:     value,
+		ExpiresAt: c.clock.Now().Add(ttl),
+		Version:   c.versions.Add(1),
+	}
 }
 
 func (c *Cache) getKeysByTag(tags []string) []string {
@@ -287,6 +459,30 @@ func (c *Cache) getKeysByTag(tags []string) []string {
 	return keys
 }
 
+func (c *Cache) getKeysByPrefix(prefixes []string) ([]string, error) {
+	keysAdaptor, ok := c.cacheAdaptor.(KeysContract)
+	if !ok {
+		return nil, ErrPrefixDeletionUnsupported
+	}
+	allKeys, err := keysAdaptor.Keys()
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, key := range allKeys {
+		if isProbeKey(key) {
+			continue
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	return keys, nil
+}
+
 func (c *Cache) cleanupMapOnThreshold() {
 	c.stats.InvalidateTag()
 	defer c.tagsMutex.Unlock()
@@ -307,6 +503,23 @@ func (c *Cache) cleanupMapOnThreshold() {
 	}
 }
 
+// GetTags returns a copy of the tag to keys index
+func (c *Cache) GetTags() map[string][]string {
+	c.tagsMutex.Lock()
+	defer c.tagsMutex.Unlock()
+	tags := make(map[string][]string, len(c.tags))
+	for tag, keys := range c.tags {
+		tags[tag] = append([]string(nil), keys...)
+	}
+	return tags
+}
+
+// Close stops the background work of the cache, the adaptor is left open
+func (c *Cache) Close() {
+	c.events.Close()
+	c.stats.Stop()
+}
+
 func (c *Cache) GetStats() *CacheStats {
 	return c.stats
 }
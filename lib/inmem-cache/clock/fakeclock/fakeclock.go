@@ -0,0 +1,91 @@
+package fakeclock
+
+import (
+	"inmem/lib/inmem-cache/clock"
+	"sync"
+	"time"
+)
+
+// Clock only moves when Advance or Set is called, tickers fire during the call
+// for every period that elapsed and drop ticks nobody received like time.Ticker does
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+type ticker struct {
+	clock  *Clock
+	period time.Duration
+	next   time.Time
+	ch     chan time.Time
+}
+
+func New(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *Clock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("fakeclock: non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &ticker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		ch:     make(chan time.Time, 1),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.now) {
+		c.now = now
+		return
+	}
+	c.now = now
+	for _, t := range c.tickers {
+		for !t.next.After(now) {
+			select {
+			case t.ch <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *ticker) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, registered := range c.tickers {
+		if registered == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
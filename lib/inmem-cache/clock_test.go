@@ -0,0 +1,55 @@
+package inmem_cache_test
+
+import (
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"testing"
+	"time"
+)
+
+func TestExpiryFollowsTheClock(t *testing.T) {
+	c, clk := newTestCache(t, time.Minute)
+	c.Set("key", "value")
+	clk.Advance(time.Minute - time.Nanosecond)
+	if val, err := c.Get("key"); err != nil || val != "value" {
+		t.Fatalf("Get just before the ttl = %v, %v", val, err)
+	}
+	clk.Advance(time.Nanosecond)
+	if _, err := c.Get("key"); !errors.Is(err, cache.ErrStaleResponse) {
+		t.Fatalf("Get at the ttl = %v, want ErrStaleResponse", err)
+	}
+	if _, err := c.Get("key"); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("Get after the stale read = %v, want the entry evicted", err)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	c, clk := newTestCache(t, time.Minute)
+	loads := 0
+	loader := func(key string) (interface{}, error) {
+		loads++
+		return loads, nil
+	}
+	c.Set("key", 0)
+
+	// inside the stale window the loader refreshes the entry
+	clk.Advance(time.Minute + 10*time.Second)
+	if val, err := c.Get("key", cache.WithStaleResponse(30*time.Second, cache.WithLoader(loader))); err != nil || val != 1 {
+		t.Fatalf("Get inside the stale window = %v, %v, want the reloaded value", val, err)
+	}
+	if val, err := c.Get("key"); err != nil || val != 1 {
+		t.Fatalf("Get after the refresh = %v, %v, want a fresh hit", val, err)
+	}
+
+	// past the stale window the entry is dropped instead
+	clk.Advance(time.Minute + time.Minute)
+	if _, err := c.Get("key", cache.WithStaleResponse(30*time.Second, cache.WithLoader(loader))); !errors.Is(err, cache.ErrStaleResponse) {
+		t.Fatalf("Get past the stale window = %v, want ErrStaleResponse", err)
+	}
+	if loads != 1 {
+		t.Fatalf("loader called %d times, want once", loads)
+	}
+	if stale := c.GetStats().Snapshot().StaleServed; stale != 2 {
+		t.Fatalf("stale reads = %d, want 2", stale)
+	}
+}
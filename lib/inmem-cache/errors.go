@@ -3,6 +3,7 @@ package inmem_cache
 import (
 	"errors"
 	"fmt"
+	"inmem/lib/logger"
 )
 
 var (
@@ -11,9 +12,16 @@ var (
 	ErrCacheAdaptorNil   = errors.New("cache adaptor is nil")
 	ErrLoaderNil         = errors.New("loader function is nil")
 	ErrInvalidCacheEntry = errors.New("invalid cache entry")
-	ErrLoaderFailed      = errors.New("loader function is nil")
+	ErrLoaderFailed      = errors.New("loader function failed")
 
-	ErrInvalidDeletionArgs = errors.New("missing deletion keys or tags")
+	ErrInvalidDeletionArgs       = errors.New("missing deletion keys or tags")
+	ErrPrefixDeletionUnsupported = errors.New("cache adaptor does not support listing keys")
+	ErrVersionConflict           = errors.New("version conflict")
+	ErrCompareAndSwapUnsupported = errors.New("cache adaptor does not support compare-and-swap")
+	ErrNotNumeric                = errors.New("value is not numeric")
+	ErrWarmupIncomplete          = errors.New("warmup finished with failed keys")
+	ErrInvalidSnapshot           = errors.New("invalid cache snapshot")
+	ErrSnapshotUnsupported       = errors.New("cache adaptor can't list its keys for a snapshot")
 )
 
 func WrapError(wrapper string, err error) error {
@@ -29,7 +37,12 @@ const (
 	SET    CacheOperation = "set"
 	DELETE CacheOperation = "delete"
 
-	SOFTDELETE CacheOperation = "softDelete"
+	SOFTDELETE     CacheOperation = "softDelete"
+	COMPAREANDSWAP CacheOperation = "compareAndSwap"
+	UPDATE         CacheOperation = "update"
+	GETORSET       CacheOperation = "getOrSet"
+	INCREMENT      CacheOperation = "increment"
+	TOUCH          CacheOperation = "touch"
 )
 
 type CacheOperation string
@@ -43,6 +56,24 @@ func (c *CacheError) Error() string {
 	return fmt.Sprintf("cache %s failed for key=%q: %v", c.Operation, c.Key, c.BaseError)
 }
 
+func (c *CacheError) Unwrap() error {
+	return c.BaseError
+}
+
+// logError keeps the key out of the message, so a sampling log dispatcher sees the failures of an
+// operation as one message however many keys fail
+func (c *Cache) logError(operation CacheOperation, key string, err error, fields ...logger.Field) {
+	var ce *CacheError
+	if errors.As(err, &ce) {
+		err = ce.BaseError
+	}
+	fields = append(fields, logger.String("op", string(operation)), logger.Err(err))
+	if key != "" {
+		fields = append(fields, logger.String("key", key))
+	}
+	c.log.Error("cache "+string(operation)+" failed", fields...)
+}
+
 func cacheError(operation CacheOperation, key string, baseError error) error {
 	return &CacheError{
 		Operation: operation,
@@ -0,0 +1,203 @@
+package inmem_cache
+
+import (
+	"inmem/lib/inmem-cache/clock"
+	"inmem/lib/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventSet       EventType = "set"
+	EventHit       EventType = "hit"
+	EventMiss      EventType = "miss"
+	EventStale     EventType = "stale"
+	EventEvict     EventType = "evict"
+	EventLoad      EventType = "load"
+	EventLoadError EventType = "loadError"
+)
+
+type EvictReason string
+
+const (
+	EvictExpired  EvictReason = "expired"
+	EvictCapacity EvictReason = "capacity"
+	EvictDeleted  EvictReason = "deleted"
+	EvictTag      EvictReason = "tag"
+)
+
+const (
+	defaultEventBufferSize = 1024
+	defaultEventWorkers    = 1
+)
+
+type CacheEvent struct {
+	Type     EventType
+	Key      string
+	Value    interface{}
+	Reason   EvictReason
+	Err      error
+	Duration time.Duration
+	Time     time.Time
+	// Loaded is set on the EventSet that stores a loader result after a miss or a stale read
+	Loaded bool
+}
+
+type Listener func(event CacheEvent)
+
+// EventDispatcher fans cache events out to listeners on its own worker goroutines.
+// Emit never blocks: when the queue is full the event is dropped and counted, so a slow
+// listener can't add latency to Get/Set.
+type EventDispatcher struct {
+	listeners  map[EventType][]Listener
+	mu         sync.RWMutex
+	subscribed atomic.Bool
+	queue      chan CacheEvent
+	workers    int
+	startOnce  sync.Once
+	dropped    atomic.Int64
+	clock      clock.Clock
+	log        *logger.Logger
+	closed     atomic.Bool
+	done       chan struct{}
+	closeOnce  sync.Once
+	wg         sync.WaitGroup
+}
+
+func NewEventDispatcher(bufferSize int, workers int) *EventDispatcher {
+	if bufferSize <= 0 {
+		bufferSize = defaultEventBufferSize
+	}
+	if workers <= 0 {
+		workers = defaultEventWorkers
+	}
+	return &EventDispatcher{
+		listeners: make(map[EventType][]Listener),
+		queue:     make(chan CacheEvent, bufferSize),
+		workers:   workers,
+		clock:     clock.Real(),
+		log:       logger.Named("cache"),
+		done:      make(chan struct{}),
+	}
+}
+
+func (e *EventDispatcher) Subscribe(eventType EventType, listener Listener) {
+	e.mu.Lock()
+	e.listeners[eventType] = append(e.listeners[eventType], listener)
+	e.mu.Unlock()
+	e.subscribed.Store(true)
+	// workers are only started once somebody is listening, caches without listeners pay nothing
+	e.startOnce.Do(func() {
+		e.wg.Add(e.workers)
+		for i := 0; i < e.workers; i++ {
+			go e.run()
+		}
+	})
+}
+
+func (e *EventDispatcher) Emit(event CacheEvent) {
+	if e == nil || !e.subscribed.Load() || e.closed.Load() {
+		return
+	}
+	event.Time = e.clock.Now()
+	select {
+	case e.queue <- event:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events discarded because the queue was full
+func (e *EventDispatcher) Dropped() int64 {
+	return e.dropped.Load()
+}
+
+// Close delivers the events already queued and stops the workers, later events are discarded
+func (e *EventDispatcher) Close() {
+	e.closeOnce.Do(func() {
+		e.closed.Store(true)
+		close(e.done)
+	})
+	e.wg.Wait()
+}
+
+func (e *EventDispatcher) run() {
+	defer e.wg.Done()
+	for {
+		select {
+		case event := <-e.queue:
+			e.notify(event)
+		case <-e.done:
+			for {
+				select {
+				case event := <-e.queue:
+					e.notify(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *EventDispatcher) notify(event CacheEvent) {
+	e.mu.RLock()
+	listeners := e.listeners[event.Type]
+	e.mu.RUnlock()
+	for _, listener := range listeners {
+		e.safeNotify(listener, event)
+	}
+}
+
+// safeNotify keeps a panicking listener from killing the worker, the panic is logged
+func (e *EventDispatcher) safeNotify(listener Listener, event CacheEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.log.Error("cache event listener panicked",
+				logger.String("event", string(event.Type)),
+				logger.String("key", event.Key),
+				logger.Object("panic", r))
+		}
+	}()
+	listener(event)
+}
+
+func (c *Cache) OnSet(listener Listener) {
+	c.events.Subscribe(EventSet, listener)
+}
+
+func (c *Cache) OnHit(listener Listener) {
+	c.events.Subscribe(EventHit, listener)
+}
+
+func (c *Cache) OnMiss(listener Listener) {
+	c.events.Subscribe(EventMiss, listener)
+}
+
+func (c *Cache) OnEvict(listener Listener) {
+	c.events.Subscribe(EventEvict, listener)
+}
+
+// OnStale fires instead of OnHit/OnMiss when a Get finds an expired entry
+func (c *Cache) OnStale(listener Listener) {
+	c.events.Subscribe(EventStale, listener)
+}
+
+func (c *Cache) OnLoad(listener Listener) {
+	c.events.Subscribe(EventLoad, listener)
+}
+
+func (c *Cache) OnLoadError(listener Listener) {
+	c.events.Subscribe(EventLoadError, listener)
+}
+
+func (c *Cache) GetEvents() *EventDispatcher {
+	return c.events
+}
+
+func (c *Cache) onAdaptorEviction(key string, reason EvictReason) {
+	c.events.Emit(CacheEvent{Type: EventEvict, Key: key, Reason: reason})
+}
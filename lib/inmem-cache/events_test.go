@@ -0,0 +1,100 @@
+package inmem_cache_test
+
+import (
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/clock/fakeclock"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"inmem/lib/logger"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, ttl time.Duration, options ...cache.OptionalCacheConfig) (*cache.Cache, *fakeclock.Clock) {
+	t.Helper()
+	clk := fakeclock.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := cache.GetCache(map_cache.CreateMapCache(), ttl, true, append([]cache.OptionalCacheConfig{cache.WithClock(clk)}, options...)...)
+	t.Cleanup(c.Close)
+	return c, clk
+}
+
+// captureLogs sends every entry to a file until the test ends and returns its path
+func captureLogs(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.log")
+	err := logger.Configure(logger.Config{
+		Level: string(logger.DEBUG),
+		Sinks: []logger.SinkConfig{{Type: "file", Path: path, Format: "json"}},
+		Async: logger.AsyncConfig{Disabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logger.Configure(logger.DefaultConfig()) })
+	return path
+}
+
+func receive(t *testing.T, events <-chan cache.CacheEvent) cache.CacheEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered")
+		return cache.CacheEvent{}
+	}
+}
+
+func TestStaleReadEmitsStaleEvent(t *testing.T) {
+	c, clk := newTestCache(t, time.Minute)
+	events := make(chan cache.CacheEvent, 4)
+	c.OnStale(func(event cache.CacheEvent) { events <- event })
+	c.OnHit(func(event cache.CacheEvent) { events <- event })
+	c.OnMiss(func(event cache.CacheEvent) { events <- event })
+
+	if err := c.Set("key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(2 * time.Minute)
+	if _, err := c.Get("key"); !errors.Is(err, cache.ErrStaleResponse) {
+		t.Fatalf("Get = %v, want ErrStaleResponse", err)
+	}
+	event := receive(t, events)
+	if event.Type != cache.EventStale || event.Key != "key" || event.Value != "value" {
+		t.Fatalf("event = %+v, want a stale event for key", event)
+	}
+}
+
+func TestCloseDeliversQueuedEventsAndStopsWorkers(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	var sets atomic.Int32
+	c.OnSet(func(cache.CacheEvent) { sets.Add(1) })
+
+	for i := 0; i < 10; i++ {
+		c.Set("key", i)
+	}
+	c.Close()
+	if got := sets.Load(); got != 10 {
+		t.Fatalf("delivered %d set events before Close returned, want 10", got)
+	}
+	c.Set("key", "after close")
+	time.Sleep(10 * time.Millisecond)
+	if got := sets.Load(); got != 10 {
+		t.Fatalf("delivered %d set events after Close, want 10", got)
+	}
+}
+
+func TestPanickingListenerKeepsDispatching(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	events := make(chan cache.CacheEvent, 4)
+	c.OnSet(func(cache.CacheEvent) { panic("listener bug") })
+	c.OnSet(func(event cache.CacheEvent) { events <- event })
+
+	c.Set("first", 1)
+	c.Set("second", 2)
+	if first, second := receive(t, events), receive(t, events); first.Key != "first" || second.Key != "second" {
+		t.Fatalf("got events for %q and %q, want first and second", first.Key, second.Key)
+	}
+}
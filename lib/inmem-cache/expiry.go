@@ -0,0 +1,75 @@
+package inmem_cache
+
+import (
+	"errors"
+	"inmem/lib/inmem-cache/clock"
+	"time"
+)
+
+// NeverExpires is the ttl for entries that should outlive the process, time.Duration's max
+// would push ExpiresAt past the year 9999 that serializing adaptors can encode
+const NeverExpires = 100 * 365 * 24 * time.Hour
+
+// WithSlidingExpiration pushes the expiry of an entry to now+window on every hit,
+// a window <= 0 uses the cache wide ttl
+func WithSlidingExpiration(window time.Duration, options ...CacheOptions) CacheOptions {
+	return func(c *cacheOptionsConfig) {
+		c.sliding = true
+		c.slidingWindow = window
+		if len(options) > 0 {
+			for _, option := range options {
+				option(c)
+			}
+		}
+	}
+}
+
+// Clock is what the cache measures expiry with, front-ends turn absolute deadlines into ttls with it
+func (c *Cache) Clock() clock.Clock {
+	return c.clock
+}
+
+// DefaultTTL is the lifetime Set gives to entries
+func (c *Cache) DefaultTTL() time.Duration {
+	return c.ttl
+}
+
+// TTL returns how long the entry for key has left before it turns stale,
+// it reads the adaptor directly so it doesn't count as a hit or a miss
+func (c *Cache) TTL(key string) (time.Duration, error) {
+	val, err := c.cacheAdaptor.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	remaining := val.ExpiresIn(c.clock.Now())
+	if remaining <= 0 {
+		return 0, ErrStaleResponse
+	}
+	return remaining, nil
+}
+
+// Touch gives a live entry a new lifetime of ttl from now without changing its value
+func (c *Cache) Touch(key string, ttl time.Duration) (err error) {
+	defer c.logUpdateError(key, TOUCH, &err)
+	_, err = c.update(key, ttl, func(old any, found bool) (any, error) {
+		if !found {
+			return nil, ErrEntryNotFound
+		}
+		return old, nil
+	})
+	return err
+}
+
+func (c *Cache) slide(key string, val *CacheEntry, window time.Duration) {
+	if window <= 0 {
+		window = c.ttl
+	}
+	// only rewrite when the expiry moves noticeably, a hot key would otherwise be rewritten on every hit
+	if val.ExpiresIn(c.clock.Now()) > window-window/10 {
+		return
+	}
+	err := c.swap(key, val.Version, c.newEntry(val.Value, window))
+	if errors.Is(err, ErrCompareAndSwapUnsupported) {
+		c.Touch(key, window)
+	}
+}
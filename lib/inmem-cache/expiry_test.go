@@ -0,0 +1,91 @@
+package inmem_cache_test
+
+import (
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	c, clk := newTestCache(t, time.Minute)
+	c.Set("key", "value")
+	clk.Advance(20 * time.Second)
+	if ttl, err := c.TTL("key"); err != nil || ttl != 40*time.Second {
+		t.Fatalf("TTL = %v, %v, want 40s", ttl, err)
+	}
+	clk.Advance(time.Minute)
+	if _, err := c.TTL("key"); !errors.Is(err, cache.ErrStaleResponse) {
+		t.Fatalf("TTL of a stale key = %v, want ErrStaleResponse", err)
+	}
+	if _, err := c.TTL("missing"); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("TTL of a missing key = %v, want ErrEntryNotFound", err)
+	}
+}
+
+func TestPeekHasNoSideEffects(t *testing.T) {
+	c, clk := newTestCache(t, time.Minute)
+	c.Set("key", "value")
+	if val, err := c.Peek("key"); err != nil || val != "value" {
+		t.Fatalf("Peek = %v, %v, want the value", val, err)
+	}
+	clk.Advance(2 * time.Minute)
+	if _, err := c.Peek("key"); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("Peek of a stale key = %v, want ErrEntryNotFound", err)
+	}
+	if _, err := c.TTL("key"); !errors.Is(err, cache.ErrStaleResponse) {
+		t.Fatalf("TTL after Peek = %v, want the stale entry still there", err)
+	}
+	if stats := c.GetStats().Snapshot(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Fatalf("stats = %+v, want no hits or misses", stats)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	c, clk := newTestCache(t, time.Minute)
+	c.Set("key", "value")
+	clk.Advance(50 * time.Second)
+	if err := c.Touch("key", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(30 * time.Minute)
+	if val, err := c.Get("key"); err != nil || val != "value" {
+		t.Fatalf("Get = %v, %v, want the touched value", val, err)
+	}
+	if err := c.Touch("missing", time.Hour); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("Touch of a missing key = %v, want ErrEntryNotFound", err)
+	}
+}
+
+func TestTouchOfMissingKeyIsNotLogged(t *testing.T) {
+	logs := captureLogs(t)
+	c, _ := newTestCache(t, time.Minute)
+	if err := c.Touch("missing", time.Hour); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("Touch = %v, want ErrEntryNotFound", err)
+	}
+	content, err := os.ReadFile(logs)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "cache touch failed") {
+		t.Fatalf("touching a missing key was logged as an error:\n%s", content)
+	}
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	c, clk := newTestCache(t, time.Minute)
+	c.Set("key", "value")
+	// each read lands before the previous expiry and pushes it a window further
+	for i := 0; i < 5; i++ {
+		clk.Advance(40 * time.Second)
+		if _, err := c.Get("key", cache.WithSlidingExpiration(time.Minute)); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+	clk.Advance(61 * time.Second)
+	if _, err := c.Get("key"); err == nil {
+		t.Fatal("Get after a full idle window still hit")
+	}
+}
@@ -0,0 +1,87 @@
+package inmem_cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inmem/lib/health"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// healthProbePrefix starts the key of every probe, each probe writes its own key so concurrent probes can't
+	// read each other's value. Keys with the prefix are hidden from prefix deletes and snapshots.
+	healthProbePrefix = "__health__:probe:"
+	// defaultLoaderFailureThreshold consecutive loader failures mark the loader as down
+	defaultLoaderFailureThreshold = 5
+)
+
+var (
+	ErrProbeMismatch = errors.New("health probe read back a different value")
+	ErrLoaderFailing = errors.New("loader is failing")
+	ErrWarmupRunning = errors.New("warmup still running")
+)
+
+// AdaptorProbe does a Set, Get and Delete round trip on the adaptor under a reserved key,
+// it bypasses the Cache so probes don't show up in stats or events
+func (c *Cache) AdaptorProbe() health.Check {
+	return func(ctx context.Context) error {
+		now := c.clock.Now()
+		key := healthProbePrefix + strconv.FormatUint(c.probes.Add(1), 10)
+		want := strconv.FormatInt(now.UnixNano(), 10)
+		if err := c.cacheAdaptor.Set(key, &CacheEntry{Value: want, ExpiresAt: now.Add(time.Minute)}); err != nil {
+			return err
+		}
+		got, err := c.cacheAdaptor.Get(key)
+		if err == nil && fmt.Sprint(got.Value) != want {
+			err = ErrProbeMismatch
+		}
+		if err != nil {
+			// adaptors without expiry would keep a failed probe's key forever
+			c.cacheAdaptor.Delete(key)
+			return err
+		}
+		return c.cacheAdaptor.Delete(key)
+	}
+}
+
+// isProbeKey reports keys written by AdaptorProbe
+func isProbeKey(key string) bool {
+	return strings.HasPrefix(key, healthProbePrefix)
+}
+
+// LoaderCheck reports the loader as down once threshold loads in a row failed, the next
+// successful load brings it back up. threshold <= 0 uses the default of 5.
+func (c *Cache) LoaderCheck(threshold int) health.Check {
+	if threshold <= 0 {
+		threshold = defaultLoaderFailureThreshold
+	}
+	return func(ctx context.Context) error {
+		if failures := c.loadFailures.Load(); int(failures) >= threshold {
+			return fmt.Errorf("%w: %d consecutive failures", ErrLoaderFailing, failures)
+		}
+		return nil
+	}
+}
+
+// WarmupCheck is down while a warmup runs
+func (c *Cache) WarmupCheck() health.Check {
+	return func(ctx context.Context) error {
+		select {
+		case <-c.Ready():
+			return nil
+		default:
+			return ErrWarmupRunning
+		}
+	}
+}
+
+// RegisterHealth adds the adaptor probe as a liveness check and the loader and warmup
+// checks as readiness checks, all prefixed with name
+func (c *Cache) RegisterHealth(registry *health.Registry, name string) {
+	registry.AddLivenessCheck(name+".adaptor", c.AdaptorProbe())
+	registry.AddReadinessCheck(name+".loader", c.LoaderCheck(0))
+	registry.AddReadinessCheck(name+".warmup", c.WarmupCheck())
+}
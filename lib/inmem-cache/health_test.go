@@ -0,0 +1,100 @@
+package inmem_cache_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentAdaptorProbes(t *testing.T) {
+	adaptor := map_cache.CreateMapCache()
+	c := cache.GetCache(adaptor, time.Minute, true)
+	t.Cleanup(c.Close)
+	probe := c.AdaptorProbe()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := probe(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if keys, _ := adaptor.Keys(); len(keys) != 0 {
+		t.Fatalf("probes left %v behind", keys)
+	}
+}
+
+// failingGets breaks reads so the probe fails after its write
+type failingGets struct {
+	*map_cache.MapCacheAdapter
+}
+
+func (failingGets) Get(string) (*cache.CacheEntry, error) {
+	return nil, errors.New("read failed")
+}
+
+func TestFailedProbeRemovesItsKey(t *testing.T) {
+	adaptor := failingGets{map_cache.CreateMapCache()}
+	c := cache.GetCache(adaptor, time.Minute, true)
+	t.Cleanup(c.Close)
+	if err := c.AdaptorProbe()(context.Background()); err == nil {
+		t.Fatal("probe passed with failing reads")
+	}
+	if keys, _ := adaptor.Keys(); len(keys) != 0 {
+		t.Fatalf("failed probe left %v behind", keys)
+	}
+}
+
+func TestProbeKeysAreHidden(t *testing.T) {
+	adaptor := map_cache.CreateMapCache()
+	c := cache.GetCache(adaptor, time.Minute, true)
+	t.Cleanup(c.Close)
+	// a probe in flight, its key is in the adaptor
+	adaptor.Set("__health__:probe:1", &cache.CacheEntry{Value: "1", ExpiresAt: time.Now().Add(time.Minute)})
+	c.Set("__health__:user", "value")
+
+	var snapshot bytes.Buffer
+	if err := c.WriteSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(snapshot.String(), "probe") {
+		t.Fatalf("snapshot contains the probe key:\n%s", snapshot.String())
+	}
+	deletionRes, err := c.Delete(cache.DeleteWithPrefix([]string{"__health__"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deletionRes.Success) != 1 || deletionRes.Success[0] != "__health__:user" {
+		t.Fatalf("prefix delete removed %v, want only the user key", deletionRes.Success)
+	}
+	if _, err := adaptor.Get("__health__:probe:1"); err != nil {
+		t.Fatalf("prefix delete removed the probe key: %v", err)
+	}
+}
+
+func TestLoaderCheck(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	check := c.LoaderCheck(2)
+	failing := cache.WithLoader(func(string) (interface{}, error) { return nil, errors.New("down") })
+	c.Get("a", failing)
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("check after one failure = %v", err)
+	}
+	c.Get("b", failing)
+	if err := check(context.Background()); !errors.Is(err, cache.ErrLoaderFailing) {
+		t.Fatalf("check after two failures = %v, want ErrLoaderFailing", err)
+	}
+	c.Get("c", cache.WithLoader(func(string) (interface{}, error) { return "up", nil }))
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("check after a successful load = %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package inmem_cache
+
+import "sync"
+
+type InvalidationKind string
+
+const (
+	InvalidateKeys     InvalidationKind = "keys"
+	InvalidateTags     InvalidationKind = "tags"
+	InvalidatePrefixes InvalidationKind = "prefixes"
+)
+
+// Invalidation describes a caller initiated Delete or SoftDelete.
+// Origin is empty for local calls and set to the remote node id when applied through DeleteFromOrigin.
+type Invalidation struct {
+	Kind   InvalidationKind
+	Values []string
+	Soft   bool
+	Origin string
+}
+
+type InvalidationListener func(invalidation Invalidation)
+
+type invalidationListeners struct {
+	mu        sync.RWMutex
+	listeners []InvalidationListener
+}
+
+func (i *invalidationListeners) add(listener InvalidationListener) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.listeners = append(i.listeners, listener)
+}
+
+func (i *invalidationListeners) notify(invalidation Invalidation) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, listener := range i.listeners {
+		listener(invalidation)
+	}
+}
+
+// OnInvalidate registers a listener that runs synchronously after every Delete and SoftDelete,
+// unlike the lifecycle events it is never dropped so it is meant for replication
+func (c *Cache) OnInvalidate(listener InvalidationListener) {
+	c.invalidations.add(listener)
+}
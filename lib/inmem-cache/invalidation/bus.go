@@ -0,0 +1,235 @@
+package invalidation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/logger"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultDedupSize     = 4096
+	defaultPublishBuffer = 256
+)
+
+var (
+	ErrCacheNil     = errors.New("cache is nil")
+	ErrTransportNil = errors.New("invalidation transport is nil")
+	ErrBusClosed    = errors.New("invalidation bus is closed")
+)
+
+var log = logger.Named("invalidation")
+
+type Message struct {
+	ID     string                 `json:"id"`
+	Origin string                 `json:"origin"`
+	Kind   cache.InvalidationKind `json:"kind"`
+	Values []string               `json:"values"`
+	Soft   bool                   `json:"soft,omitempty"`
+}
+
+// Bus broadcasts the invalidations of a local Cache over a Transport and applies the ones
+// received from other nodes. Remote invalidations are applied with cache.DeleteFromOrigin so
+// they are never published again, and message ids are remembered to drop duplicates.
+type Bus struct {
+	nodeID        string
+	cache         *cache.Cache
+	transport     Transport
+	seen          *dedup
+	sequence      atomic.Uint64
+	outgoing      chan Message
+	dedupSize     int
+	publishBuffer int
+	closed        atomic.Bool
+	closeOnce     sync.Once
+	done          chan struct{}
+	dropped       atomic.Int64
+}
+
+type OptionalBusConfig func(b *Bus)
+
+func WithNodeID(nodeID string) OptionalBusConfig {
+	return func(b *Bus) {
+		b.nodeID = nodeID
+	}
+}
+
+func WithDedupSize(size int) OptionalBusConfig {
+	return func(b *Bus) {
+		b.dedupSize = size
+	}
+}
+
+func WithPublishBuffer(size int) OptionalBusConfig {
+	return func(b *Bus) {
+		b.publishBuffer = size
+	}
+}
+
+func NewBus(c *cache.Cache, transport Transport, optionalBusConfigs ...OptionalBusConfig) (*Bus, error) {
+	if c == nil {
+		return nil, ErrCacheNil
+	}
+	if transport == nil {
+		return nil, ErrTransportNil
+	}
+	bus := &Bus{
+		cache:         c,
+		transport:     transport,
+		dedupSize:     defaultDedupSize,
+		publishBuffer: defaultPublishBuffer,
+		done:          make(chan struct{}),
+	}
+	for _, option := range optionalBusConfigs {
+		option(bus)
+	}
+	if bus.nodeID == "" {
+		bus.nodeID = randomNodeID()
+	}
+	bus.seen = newDedup(bus.dedupSize)
+	bus.outgoing = make(chan Message, bus.publishBuffer)
+	if err := transport.Subscribe(bus.receive); err != nil {
+		return nil, err
+	}
+	go bus.publishLoop()
+	c.OnInvalidate(bus.onInvalidate)
+	return bus, nil
+}
+
+func (b *Bus) NodeID() string {
+	return b.nodeID
+}
+
+func (b *Bus) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.closed.Store(true)
+		close(b.done)
+		err = b.transport.Close()
+	})
+	return err
+}
+
+func (b *Bus) onInvalidate(invalidation cache.Invalidation) {
+	// loop suppression, this one came from another node through receive
+	if invalidation.Origin != "" || b.closed.Load() {
+		return
+	}
+	msg := Message{
+		ID:     b.nodeID + "-" + strconv.FormatUint(b.sequence.Add(1), 10),
+		Origin: b.nodeID,
+		Kind:   invalidation.Kind,
+		Values: invalidation.Values,
+		Soft:   invalidation.Soft,
+	}
+	b.seen.add(msg.ID)
+	// runs inside Delete and SoftDelete, a slow transport must not block them
+	select {
+	case b.outgoing <- msg:
+	default:
+		b.dropped.Add(1)
+	}
+}
+
+// Dropped is the number of local invalidations discarded because the publish buffer was full,
+// the other nodes keep their copies of those keys until they expire
+func (b *Bus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+func (b *Bus) publishLoop() {
+	for {
+		select {
+		case msg := <-b.outgoing:
+			payload, err := json.Marshal(msg)
+			if err == nil {
+				err = b.transport.Publish(payload)
+			}
+			if err != nil {
+				log.Error("invalidation publish failed",
+					logger.Err(err),
+					logger.String("id", msg.ID),
+					logger.String("op", "invalidationPublish"))
+			}
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *Bus) receive(payload []byte) {
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		log.Warn("dropping malformed invalidation message",
+			logger.Err(err),
+			logger.String("op", "invalidationReceive"))
+		return
+	}
+	if msg.Origin == b.nodeID || !b.seen.add(msg.ID) {
+		return
+	}
+	b.apply(msg)
+}
+
+func (b *Bus) apply(msg Message) {
+	origin := cache.DeleteFromOrigin(msg.Origin)
+	if msg.Soft {
+		for _, key := range msg.Values {
+			b.cache.SoftDelete(key, origin)
+		}
+		return
+	}
+	switch msg.Kind {
+	case cache.InvalidateKeys:
+		b.cache.Delete(cache.DeleteWithKeys(msg.Values), origin)
+	case cache.InvalidateTags:
+		b.cache.Delete(cache.DeleteWithTags(msg.Values), origin)
+	case cache.InvalidatePrefixes:
+		b.cache.Delete(cache.DeleteWithPrefix(msg.Values), origin)
+	}
+}
+
+func randomNodeID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// dedup remembers the last size message ids in insertion order
+type dedup struct {
+	mu   sync.Mutex
+	ids  map[string]struct{}
+	ring []string
+	next int
+}
+
+func newDedup(size int) *dedup {
+	if size <= 0 {
+		size = defaultDedupSize
+	}
+	return &dedup{
+		ids:  make(map[string]struct{}, size),
+		ring: make([]string, size),
+	}
+}
+
+// add returns false when the id was already seen
+func (d *dedup) add(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.ids[id]; ok {
+		return false
+	}
+	if old := d.ring[d.next]; old != "" {
+		delete(d.ids, old)
+	}
+	d.ring[d.next] = id
+	d.ids[id] = struct{}{}
+	d.next = (d.next + 1) % len(d.ring)
+	return true
+}
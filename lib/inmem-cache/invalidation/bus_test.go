@@ -0,0 +1,107 @@
+package invalidation
+
+import (
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newNode(t *testing.T, transport Transport, optionalBusConfigs ...OptionalBusConfig) (*cache.Cache, *Bus) {
+	t.Helper()
+	c := cache.GetCache(map_cache.CreateMapCache(), time.Minute, true)
+	bus, err := NewBus(c, transport, optionalBusConfigs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		bus.Close()
+		c.Close()
+	})
+	return c, bus
+}
+
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeleteIsAppliedOnOtherNodes(t *testing.T) {
+	network := NewMemoryNetwork()
+	first, _ := newNode(t, network.Transport())
+	second, _ := newNode(t, network.Transport())
+	first.Set("key", "value")
+	second.Set("key", "value")
+
+	if _, err := first.Delete(cache.DeleteWithKeys([]string{"key"})); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return !second.Exists("key") })
+}
+
+// recordingTransport counts publishes and can be made to block them
+type recordingTransport struct {
+	published atomic.Int32
+	block     chan struct{}
+}
+
+func (r *recordingTransport) Publish([]byte) error {
+	if r.block != nil {
+		<-r.block
+	}
+	r.published.Add(1)
+	return nil
+}
+
+func (r *recordingTransport) Subscribe(func([]byte)) error { return nil }
+
+func (r *recordingTransport) Close() error { return nil }
+
+func TestFailedSoftDeleteIsNotPublished(t *testing.T) {
+	transport := &recordingTransport{}
+	c, _ := newNode(t, transport)
+
+	if err := c.SoftDelete("missing"); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("SoftDelete = %v, want ErrEntryNotFound", err)
+	}
+	c.Set("present", "value")
+	if err := c.SoftDelete("present"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return transport.published.Load() == 1 })
+	time.Sleep(20 * time.Millisecond)
+	if got := transport.published.Load(); got != 1 {
+		t.Fatalf("published %d invalidations, want only the successful soft delete", got)
+	}
+}
+
+func TestStuckTransportDoesNotBlockDelete(t *testing.T) {
+	transport := &recordingTransport{block: make(chan struct{})}
+	defer close(transport.block)
+	c, bus := newNode(t, transport, WithPublishBuffer(1))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			c.Set("key", i)
+			c.Delete(cache.DeleteWithKeys([]string{"key"}))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Delete blocked on a stuck transport")
+	}
+	if bus.Dropped() == 0 {
+		t.Fatal("no invalidation counted as dropped")
+	}
+}
@@ -0,0 +1,243 @@
+package invalidation
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	maxFrameSize        = 1 << 20
+	defaultDialTimeout  = time.Second
+	defaultWriteTimeout = time.Second
+	maxAcceptBackoff    = time.Second
+)
+
+var ErrFrameTooLarge = errors.New("invalidation frame too large")
+
+// TCPTransport sends every payload to a static list of peers as length prefixed frames and
+// accepts frames from them on its own listener. Peer connections are dialed lazily and redialed
+// once when a write fails. Peers are written to concurrently and every write has a deadline,
+// so a stuck peer delays a Publish by the write timeout at most.
+type TCPTransport struct {
+	listener     net.Listener
+	peers        map[string]*peerConn
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+	inboundMu    sync.Mutex
+	inbound      map[net.Conn]struct{}
+	handler      func(payload []byte)
+	handlerMu    sync.RWMutex
+	closed       chan struct{}
+	closeOnce    sync.Once
+	wg           sync.WaitGroup
+}
+
+type peerConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+type OptionalTCPConfig func(t *TCPTransport)
+
+// WithWriteTimeout bounds every frame write to a peer, a timed out connection is redialed on the next publish
+func WithWriteTimeout(timeout time.Duration) OptionalTCPConfig {
+	return func(t *TCPTransport) {
+		t.writeTimeout = timeout
+	}
+}
+
+func WithDialTimeout(timeout time.Duration) OptionalTCPConfig {
+	return func(t *TCPTransport) {
+		t.dialTimeout = timeout
+	}
+}
+
+func NewTCPTransport(listenAddr string, peers []string, optionalTCPConfigs ...OptionalTCPConfig) (*TCPTransport, error) {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	transport := &TCPTransport{
+		listener:     listener,
+		peers:        make(map[string]*peerConn, len(peers)),
+		dialTimeout:  defaultDialTimeout,
+		writeTimeout: defaultWriteTimeout,
+		inbound:      make(map[net.Conn]struct{}),
+		closed:       make(chan struct{}),
+	}
+	for _, peer := range peers {
+		transport.peers[peer] = &peerConn{}
+	}
+	for _, option := range optionalTCPConfigs {
+		option(transport)
+	}
+	transport.wg.Add(1)
+	go transport.acceptLoop()
+	return transport, nil
+}
+
+func (t *TCPTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *TCPTransport) Subscribe(handler func(payload []byte)) error {
+	t.handlerMu.Lock()
+	defer t.handlerMu.Unlock()
+	t.handler = handler
+	return nil
+}
+
+func (t *TCPTransport) Publish(payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return ErrFrameTooLarge
+	}
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+
+	var (
+		mu         sync.Mutex
+		publishErr error
+		wg         sync.WaitGroup
+	)
+	for addr, peer := range t.peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := t.send(addr, peer, frame); err != nil {
+				mu.Lock()
+				publishErr = errors.Join(publishErr, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return publishErr
+}
+
+func (t *TCPTransport) send(addr string, peer *peerConn, frame []byte) error {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		if peer.conn == nil {
+			conn, err := net.DialTimeout("tcp", addr, t.dialTimeout)
+			if err != nil {
+				return err
+			}
+			peer.conn = conn
+		}
+		peer.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
+		_, err := peer.conn.Write(frame)
+		if err == nil {
+			return nil
+		}
+		// a partial frame can't be resumed, the connection is dropped and the reader starts over
+		peer.conn.Close()
+		peer.conn = nil
+		if attempt == 1 {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TCPTransport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		err = t.listener.Close()
+		for _, peer := range t.peers {
+			peer.mu.Lock()
+			if peer.conn != nil {
+				peer.conn.Close()
+				peer.conn = nil
+			}
+			peer.mu.Unlock()
+		}
+		t.inboundMu.Lock()
+		for conn := range t.inbound {
+			conn.Close()
+		}
+		t.inboundMu.Unlock()
+		t.wg.Wait()
+	})
+	return err
+}
+
+func (t *TCPTransport) acceptLoop() {
+	defer t.wg.Done()
+	var backoff time.Duration
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			// errors like EMFILE persist for a while, back off like net/http does instead of spinning
+			if backoff == 0 {
+				backoff = 5 * time.Millisecond
+			} else {
+				backoff = min(2*backoff, maxAcceptBackoff)
+			}
+			select {
+			case <-t.closed:
+				return
+			case <-time.After(backoff):
+				continue
+			}
+		}
+		backoff = 0
+		if !t.track(conn) {
+			conn.Close()
+			return
+		}
+		t.wg.Add(1)
+		go t.readLoop(conn)
+	}
+}
+
+// track registers an inbound connection for Close, it reports false once the transport is closed
+func (t *TCPTransport) track(conn net.Conn) bool {
+	t.inboundMu.Lock()
+	defer t.inboundMu.Unlock()
+	select {
+	case <-t.closed:
+		return false
+	default:
+	}
+	t.inbound[conn] = struct{}{}
+	return true
+}
+
+func (t *TCPTransport) readLoop(conn net.Conn) {
+	defer t.wg.Done()
+	defer func() {
+		t.inboundMu.Lock()
+		delete(t.inbound, conn)
+		t.inboundMu.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size > maxFrameSize {
+			return
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return
+		}
+		t.handlerMu.RLock()
+		handler := t.handler
+		t.handlerMu.RUnlock()
+		if handler != nil {
+			handler(payload)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package invalidation
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTCPTransportDeliversToPeers(t *testing.T) {
+	receiver, err := NewTCPTransport("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+	received := make(chan []byte, 1)
+	receiver.Subscribe(func(payload []byte) { received <- payload })
+
+	sender, err := NewTCPTransport("127.0.0.1:0", []string{receiver.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if err := sender.Publish([]byte("payload")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-received:
+		if !bytes.Equal(payload, []byte("payload")) {
+			t.Fatalf("received %q", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("payload not delivered")
+	}
+}
+
+func TestTCPTransportStuckPeerTimesOut(t *testing.T) {
+	// the stuck peer accepts connections and never reads, its socket buffers fill up
+	stuck, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stuck.Close()
+	go func() {
+		for {
+			conn, err := stuck.Accept()
+			if err != nil {
+				return
+			}
+			// a fixed small receive buffer keeps the kernel from absorbing megabytes before writes block
+			conn.(*net.TCPConn).SetReadBuffer(4096)
+			defer conn.Close()
+		}
+	}()
+
+	healthy, err := NewTCPTransport("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer healthy.Close()
+	var delivered atomic.Int32
+	healthy.Subscribe(func([]byte) { delivered.Add(1) })
+
+	sender, err := NewTCPTransport("127.0.0.1:0", []string{stuck.Addr().String(), healthy.Addr().String()},
+		WithWriteTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	// without a write deadline the publishes stop once the stuck peer's buffers are full
+	payload := make([]byte, maxFrameSize)
+	const publishes = 32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < publishes; i++ {
+			sender.Publish(payload)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Publish blocked on a stuck peer")
+	}
+	eventually(t, func() bool { return delivered.Load() == publishes })
+}
+
+func TestTCPTransportCloseStopsReaders(t *testing.T) {
+	receiver, err := NewTCPTransport("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", receiver.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	eventually(t, func() bool {
+		receiver.inboundMu.Lock()
+		defer receiver.inboundMu.Unlock()
+		return len(receiver.inbound) == 1
+	})
+
+	closed := make(chan struct{})
+	go func() {
+		receiver.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close waited on an idle inbound connection")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("inbound connection still open after Close")
+	}
+}
@@ -0,0 +1,70 @@
+package invalidation
+
+import "sync"
+
+// Transport moves opaque invalidation payloads between nodes. Subscribe is called once by the
+// Bus before anything is published, the handler may be invoked concurrently.
+type Transport interface {
+	Publish(payload []byte) error
+	Subscribe(handler func(payload []byte)) error
+	Close() error
+}
+
+// MemoryNetwork connects in-process transports, every payload published on one of them is
+// delivered to all of them, the publisher included, the way a multicast group loops back.
+type MemoryNetwork struct {
+	mu         sync.RWMutex
+	transports map[*MemoryTransport]struct{}
+}
+
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{
+		transports: make(map[*MemoryTransport]struct{}),
+	}
+}
+
+func (n *MemoryNetwork) Transport() *MemoryTransport {
+	transport := &MemoryTransport{network: n}
+	n.mu.Lock()
+	n.transports[transport] = struct{}{}
+	n.mu.Unlock()
+	return transport
+}
+
+type MemoryTransport struct {
+	network *MemoryNetwork
+	mu      sync.RWMutex
+	handler func(payload []byte)
+}
+
+func (m *MemoryTransport) Publish(payload []byte) error {
+	m.network.mu.RLock()
+	defer m.network.mu.RUnlock()
+	for transport := range m.network.transports {
+		transport.deliver(payload)
+	}
+	return nil
+}
+
+func (m *MemoryTransport) Subscribe(handler func(payload []byte)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handler = handler
+	return nil
+}
+
+func (m *MemoryTransport) Close() error {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	delete(m.network.transports, m)
+	return nil
+}
+
+func (m *MemoryTransport) deliver(payload []byte) {
+	m.mu.RLock()
+	handler := m.handler
+	m.mu.RUnlock()
+	if handler != nil {
+		handler(append([]byte(nil), payload...))
+	}
+}
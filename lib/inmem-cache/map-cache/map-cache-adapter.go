@@ -0,0 +1,82 @@
+package map_cache
+
+import (
+	cache "inmem/lib/inmem-cache"
+	"sync"
+)
+
+// MapCacheAdapter keeps entries in a plain map behind a RWMutex, values are stored as is
+// without serialization. Nothing is ever evicted, expiry is left to the Cache.
+type MapCacheAdapter struct {
+	mu      sync.RWMutex
+	entries map[string]cache.CacheEntry
+}
+
+func CreateMapCache() *MapCacheAdapter {
+	return &MapCacheAdapter{
+		entries: make(map[string]cache.CacheEntry),
+	}
+}
+
+func (m *MapCacheAdapter) Get(key string) (*cache.CacheEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, cache.ErrEntryNotFound
+	}
+	return &entry, nil
+}
+
+func (m *MapCacheAdapter) Set(key string, cacheEntry *cache.CacheEntry) error {
+	if cacheEntry == nil {
+		return cache.ErrInvalidCacheEntry
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = *cacheEntry
+	return nil
+}
+
+func (m *MapCacheAdapter) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[key]; !ok {
+		return cache.ErrEntryNotFound
+	}
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *MapCacheAdapter) CompareAndSwap(key string, expectedVersion uint64, cacheEntry *cache.CacheEntry) error {
+	if cacheEntry == nil {
+		return cache.ErrInvalidCacheEntry
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.entries[key]
+	switch {
+	case !ok && expectedVersion != 0:
+		return cache.ErrEntryNotFound
+	case ok && current.Version != expectedVersion:
+		return cache.ErrVersionConflict
+	}
+	m.entries[key] = *cacheEntry
+	return nil
+}
+
+func (m *MapCacheAdapter) Keys() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *MapCacheAdapter) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
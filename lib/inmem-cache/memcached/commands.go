@@ -0,0 +1,299 @@
+package memcached
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxKeyLength  = 250
+	maxDataLength = 1024 * 1024
+	version       = "1.6.0-inmem"
+)
+
+var errBadDataChunk = errors.New("bad data chunk")
+
+type storageMode int
+
+const (
+	modeSet storageMode = iota
+	modeAdd
+	modeReplace
+	modeCas
+)
+
+// execute runs one command line, the returned error means the connection is unusable
+func (s *Server) execute(rd *bufio.Reader, wr *bufio.Writer, line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		wr.WriteString("ERROR\r\n")
+		return false, nil
+	}
+	switch fields[0] {
+	case "get":
+		s.get(wr, fields[1:], false)
+	case "gets":
+		s.get(wr, fields[1:], true)
+	case "set":
+		return false, s.storage(rd, wr, fields[1:], modeSet)
+	case "add":
+		return false, s.storage(rd, wr, fields[1:], modeAdd)
+	case "replace":
+		return false, s.storage(rd, wr, fields[1:], modeReplace)
+	case "cas":
+		return false, s.storage(rd, wr, fields[1:], modeCas)
+	case "delete":
+		s.delete(wr, fields[1:])
+	case "touch":
+		s.touch(wr, fields[1:])
+	case "stats":
+		s.stats(wr)
+	case "version":
+		wr.WriteString("VERSION " + version + "\r\n")
+	case "quit":
+		return true, nil
+	default:
+		wr.WriteString("ERROR\r\n")
+	}
+	return false, nil
+}
+
+// maxSwapAttempts bounds the read-modify-write retries of replace
+const maxSwapAttempts = 8
+
+func (s *Server) lookup(key string) (item, uint64, bool) {
+	val, version, err := s.cache.GetWithVersion(key)
+	if err != nil {
+		return item{}, 0, false
+	}
+	return decodeItem(val), version, true
+}
+
+func (s *Server) get(wr *bufio.Writer, keys []string, withCas bool) {
+	if len(keys) == 0 {
+		wr.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range keys {
+		s.counters.cmdGet.Add(1)
+		it, version, ok := s.lookup(key)
+		if !ok {
+			continue
+		}
+		if withCas {
+			fmt.Fprintf(wr, "VALUE %s %d %d %d\r\n", key, it.Flags, len(it.Data), version)
+		} else {
+			fmt.Fprintf(wr, "VALUE %s %d %d\r\n", key, it.Flags, len(it.Data))
+		}
+		wr.WriteString(it.Data)
+		wr.WriteString("\r\n")
+	}
+	wr.WriteString("END\r\n")
+}
+
+// <cmd> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply]
+func (s *Server) storage(rd *bufio.Reader, wr *bufio.Writer, args []string, mode storageMode) error {
+	required := 4
+	if mode == modeCas {
+		required = 5
+	}
+	if len(args) < required || len(args) > required+1 {
+		wr.WriteString("ERROR\r\n")
+		return nil
+	}
+	noreply := len(args) == required+1 && args[required] == "noreply"
+	key := args[0]
+	flags, flagsErr := strconv.ParseUint(args[1], 10, 32)
+	exptime, exptimeErr := strconv.ParseInt(args[2], 10, 64)
+	size, sizeErr := strconv.Atoi(args[3])
+	if flagsErr != nil || exptimeErr != nil || sizeErr != nil || size < 0 || size > maxDataLength {
+		wr.WriteString("CLIENT_ERROR bad command line format\r\n")
+		// the data block can't be located without a valid size, drop the connection
+		if sizeErr != nil || size < 0 || size > maxDataLength {
+			return errBadDataChunk
+		}
+		_, err := readData(rd, size)
+		return err
+	}
+	var casUnique uint64
+	if mode == modeCas {
+		var err error
+		if casUnique, err = strconv.ParseUint(args[4], 10, 64); err != nil {
+			wr.WriteString("CLIENT_ERROR bad command line format\r\n")
+			_, err = readData(rd, size)
+			return err
+		}
+	}
+	data, err := readData(rd, size)
+	if err != nil {
+		if errors.Is(err, errBadDataChunk) {
+			wr.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		}
+		return err
+	}
+	if len(key) > maxKeyLength {
+		reply(wr, noreply, "CLIENT_ERROR key too long")
+		return nil
+	}
+	s.counters.cmdSet.Add(1)
+	it := item{Flags: uint32(flags), Data: data}
+
+	ttl, expired := exptimeToTTL(exptime, s.cache.Clock().Now())
+	switch mode {
+	case modeSet:
+		if expired {
+			s.remove(key)
+		} else {
+			err = s.cache.SetWithTTL(key, it.value(), ttl)
+		}
+	case modeAdd:
+		err = s.cache.CompareAndSwapWithTTL(key, 0, it.value(), ttl)
+	case modeReplace:
+		err = s.modify(key, func(item) item { return it }, ttl)
+	case modeCas:
+		err = s.cache.CompareAndSwapWithTTL(key, casUnique, it.value(), ttl)
+		switch {
+		case err == nil:
+			s.counters.casHits.Add(1)
+		case errors.Is(err, cache.ErrEntryNotFound):
+			s.counters.casMisses.Add(1)
+		case errors.Is(err, cache.ErrVersionConflict):
+			s.counters.casBadval.Add(1)
+		}
+	}
+	// an expired add, replace or cas still checks its condition, the stored item goes right away
+	if err == nil && expired && mode != modeSet {
+		s.remove(key)
+	}
+	switch {
+	case err == nil:
+		reply(wr, noreply, "STORED")
+	case mode == modeCas && errors.Is(err, cache.ErrEntryNotFound):
+		reply(wr, noreply, "NOT_FOUND")
+	case mode == modeCas && errors.Is(err, cache.ErrVersionConflict):
+		reply(wr, noreply, "EXISTS")
+	case errors.Is(err, cache.ErrVersionConflict), errors.Is(err, cache.ErrEntryNotFound):
+		reply(wr, noreply, "NOT_STORED")
+	default:
+		reply(wr, noreply, "SERVER_ERROR "+err.Error())
+	}
+	return nil
+}
+
+// remove deletes key and reports whether it was there
+func (s *Server) remove(key string) bool {
+	deletionRes, _ := s.cache.Delete(cache.DeleteWithKeys([]string{key}))
+	return deletionRes != nil && len(deletionRes.Success) > 0
+}
+
+// modify rewrites an existing item, retrying when another client wrote it in between
+func (s *Server) modify(key string, update func(current item) item, ttl time.Duration) error {
+	err := cache.ErrVersionConflict
+	for attempt := 0; attempt < maxSwapAttempts && errors.Is(err, cache.ErrVersionConflict); attempt++ {
+		current, version, ok := s.lookup(key)
+		if !ok {
+			return cache.ErrEntryNotFound
+		}
+		err = s.cache.CompareAndSwapWithTTL(key, version, update(current).value(), ttl)
+	}
+	return err
+}
+
+func (s *Server) delete(wr *bufio.Writer, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		wr.WriteString("ERROR\r\n")
+		return
+	}
+	noreply := len(args) == 2 && args[1] == "noreply"
+	if !s.remove(args[0]) {
+		reply(wr, noreply, "NOT_FOUND")
+		return
+	}
+	reply(wr, noreply, "DELETED")
+}
+
+// touch <key> <exptime> [noreply], the item keeps its value and gets a new ttl
+func (s *Server) touch(wr *bufio.Writer, args []string) {
+	if len(args) < 2 || len(args) > 3 {
+		wr.WriteString("ERROR\r\n")
+		return
+	}
+	noreply := len(args) == 3 && args[2] == "noreply"
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		reply(wr, noreply, "CLIENT_ERROR invalid exptime argument")
+		return
+	}
+	s.counters.cmdTouch.Add(1)
+	ttl, expired := exptimeToTTL(exptime, s.cache.Clock().Now())
+	if expired {
+		err = cache.ErrEntryNotFound
+		if s.remove(args[0]) {
+			err = nil
+		}
+	} else {
+		err = s.cache.Touch(args[0], ttl)
+	}
+	switch {
+	case err == nil:
+		reply(wr, noreply, "TOUCHED")
+	case errors.Is(err, cache.ErrEntryNotFound):
+		reply(wr, noreply, "NOT_FOUND")
+	default:
+		reply(wr, noreply, "SERVER_ERROR "+err.Error())
+	}
+}
+
+func (s *Server) stats(wr *bufio.Writer) {
+	snapshot := s.cache.GetStats().Snapshot()
+	now := time.Now()
+	stat := func(name string, value interface{}) {
+		fmt.Fprintf(wr, "STAT %s %v\r\n", name, value)
+	}
+	stat("pid", os.Getpid())
+	stat("uptime", int64(now.Sub(s.startedAt).Seconds()))
+	stat("time", now.Unix())
+	stat("version", version)
+	stat("curr_connections", s.counters.currConnections.Load())
+	stat("total_connections", s.counters.totalConnections.Load())
+	stat("cmd_get", s.counters.cmdGet.Load())
+	stat("cmd_set", s.counters.cmdSet.Load())
+	stat("cmd_touch", s.counters.cmdTouch.Load())
+	stat("get_hits", snapshot.Hits)
+	stat("get_misses", snapshot.Misses)
+	stat("get_expired", snapshot.StaleServed)
+	stat("delete_hits", snapshot.DeleteHits)
+	stat("delete_misses", snapshot.DeleteMisses)
+	stat("cas_hits", s.counters.casHits.Load())
+	stat("cas_misses", s.counters.casMisses.Load())
+	stat("cas_badval", s.counters.casBadval.Load())
+	stat("curr_items", snapshot.LiveEntries)
+	stat("total_items", snapshot.TotalEntries)
+	stat("evictions", snapshot.Evictions)
+	wr.WriteString("END\r\n")
+}
+
+func readData(rd *bufio.Reader, size int) (string, error) {
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(rd, buf); err != nil {
+		return "", err
+	}
+	if buf[size] != '\r' || buf[size+1] != '\n' {
+		return "", errBadDataChunk
+	}
+	return string(buf[:size]), nil
+}
+
+func reply(wr *bufio.Writer, noreply bool, msg string) {
+	if noreply {
+		return
+	}
+	wr.WriteString(msg + "\r\n")
+}
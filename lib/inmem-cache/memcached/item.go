@@ -0,0 +1,63 @@
+package memcached
+
+import (
+	"encoding/json"
+	cache "inmem/lib/inmem-cache"
+	"time"
+)
+
+// relativeExptimeLimit is the memcached cut off, larger exptime values are unix timestamps
+const relativeExptimeLimit = 60 * 60 * 24 * 30
+
+// item is what gets stored for non zero flags, values without flags are stored as plain
+// strings so the resp front-end and the admin api see the same value
+type item struct {
+	Flags uint32 `json:"flags"`
+	Data  string `json:"data"`
+}
+
+func (i item) value() interface{} {
+	if i.Flags == 0 {
+		return i.Data
+	}
+	return i
+}
+
+// decodeItem accepts what the adaptors hand back, serializing adaptors turn item into a map
+func decodeItem(val interface{}) item {
+	switch v := val.(type) {
+	case string:
+		return item{Data: v}
+	case []byte:
+		return item{Data: string(v)}
+	case item:
+		return v
+	case *item:
+		return *v
+	case map[string]interface{}:
+		flags, _ := v["flags"].(float64)
+		data, ok := v["data"].(string)
+		if ok {
+			return item{Flags: uint32(flags), Data: data}
+		}
+	}
+	encoded, _ := json.Marshal(val)
+	return item{Data: string(encoded)}
+}
+
+// exptimeToTTL maps a memcached exptime, 0 never expires and expired means a negative or
+// past exptime that removes the item instead of storing it. Unix timestamps are measured
+// from now, the cache clock's time, so they expire with the entries they set.
+func exptimeToTTL(exptime int64, now time.Time) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return cache.NeverExpires, false
+	case exptime < 0:
+		return 0, true
+	case exptime > relativeExptimeLimit:
+		ttl = time.Unix(exptime, 0).Sub(now)
+		return ttl, ttl <= 0
+	default:
+		return time.Duration(exptime) * time.Second, false
+	}
+}
@@ -0,0 +1,173 @@
+package memcached
+
+import (
+	"bufio"
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/logger"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxLineLength fits a multi get of a few hundred max length keys
+const maxLineLength = 64 * 1024
+
+var (
+	ErrServerClosed = errors.New("memcached server closed")
+	ErrLineTooLong  = errors.New("line too long")
+)
+
+var log = logger.Named("memcached")
+
+// Server speaks the memcached ASCII protocol on top of a Cache so memcached clients
+// can read and write the same in-memory store
+type Server struct {
+	cache     *cache.Cache
+	startedAt time.Time
+	mu        sync.Mutex
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	closed    atomic.Bool
+	wg        sync.WaitGroup
+	counters  counters
+}
+
+type counters struct {
+	currConnections  atomic.Int64
+	totalConnections atomic.Int64
+	cmdGet           atomic.Int64
+	cmdSet           atomic.Int64
+	cmdTouch         atomic.Int64
+	casHits          atomic.Int64
+	casMisses        atomic.Int64
+	casBadval        atomic.Int64
+}
+
+func NewServer(c *cache.Cache) *Server {
+	return &Server{
+		cache:     c,
+		startedAt: time.Now(),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections until Close is called, it always returns a non nil error
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed.Load() {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.closed.Load() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		// Close may have run between Accept and here, it won't see conn in s.conns then
+		s.mu.Lock()
+		if s.closed.Load() {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	s.counters.currConnections.Add(1)
+	s.counters.totalConnections.Add(1)
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.counters.currConnections.Add(-1)
+		s.wg.Done()
+	}()
+	rd := bufio.NewReader(conn)
+	wr := bufio.NewWriter(conn)
+	for {
+		line, err := readLine(rd)
+		if err != nil {
+			if errors.Is(err, ErrLineTooLong) {
+				wr.WriteString("CLIENT_ERROR " + err.Error() + "\r\n")
+				wr.Flush()
+			} else if !errors.Is(err, io.EOF) && !s.closed.Load() {
+				log.Warn("memcached read failed",
+					logger.Err(err),
+					logger.String("remote", conn.RemoteAddr().String()),
+					logger.String("op", "memcachedRead"))
+			}
+			return
+		}
+		quit, err := s.execute(rd, wr, line)
+		if err != nil {
+			wr.Flush()
+			return
+		}
+		if rd.Buffered() == 0 || quit {
+			if err = wr.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// readLine returns the next line with its terminator, lines over maxLineLength are never buffered whole
+func readLine(rd *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := rd.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLength {
+			return "", ErrLineTooLong
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			return string(line), nil
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/clock/fakeclock"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+func startServer(t *testing.T) (*client, *fakeclock.Clock) {
+	t.Helper()
+	clk := fakeclock.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := cache.GetCache(map_cache.CreateMapCache(), time.Minute, true, cache.WithClock(clk))
+	t.Cleanup(c.Close)
+	server := NewServer(c)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{t: t, conn: conn, rd: bufio.NewReader(conn)}, clk
+}
+
+// command sends raw and expects one reply line per want
+func (c *client) command(raw string, want ...string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(raw)); err != nil {
+		c.t.Fatal(err)
+	}
+	for _, expected := range want {
+		line, err := c.rd.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("%q: %v", raw, err)
+		}
+		if got := strings.TrimRight(line, "\r\n"); got != expected {
+			c.t.Fatalf("%q replied %q, want %q", raw, got, expected)
+		}
+	}
+}
+
+func TestStorageCommands(t *testing.T) {
+	c, _ := startServer(t)
+	c.command("set key 5 0 5\r\nvalue\r\n", "STORED")
+	c.command("get key missing\r\n", "VALUE key 5 5", "value", "END")
+	c.command("add key 0 0 1\r\nx\r\n", "NOT_STORED")
+	c.command("replace missing 0 0 1\r\nx\r\n", "NOT_STORED")
+	c.command("replace key 0 0 1\r\nx\r\n", "STORED")
+	c.command("delete key\r\n", "DELETED")
+	c.command("delete key\r\n", "NOT_FOUND")
+}
+
+func TestZeroExptimeNeverExpires(t *testing.T) {
+	c, clk := startServer(t)
+	c.command("set stored 0 0 1\r\na\r\n", "STORED")
+	c.command("set touched 0 10 1\r\nb\r\n", "STORED")
+	c.command("touch touched 0\r\n", "TOUCHED")
+
+	// well past both the 10s exptime and the cache wide ttl of a minute
+	clk.Advance(365 * 24 * time.Hour)
+	c.command("get stored touched\r\n", "VALUE stored 0 1", "a", "VALUE touched 0 1", "b", "END")
+}
+
+func TestExptimeExpiresItems(t *testing.T) {
+	c, clk := startServer(t)
+	c.command("set key 0 10 1\r\na\r\n", "STORED")
+	clk.Advance(11 * time.Second)
+	c.command("get key\r\n", "END")
+}
+
+func TestUnixExptimeFollowsTheCacheClock(t *testing.T) {
+	c, clk := startServer(t)
+	deadline := clk.Now().Add(10 * time.Second).Unix()
+	c.command(fmt.Sprintf("set key 0 %d 1\r\na\r\n", deadline), "STORED")
+	c.command("get key\r\n", "VALUE key 0 1", "a", "END")
+	clk.Advance(11 * time.Second)
+	c.command("get key\r\n", "END")
+}
+
+func TestNegativeExptimeDeletes(t *testing.T) {
+	c, _ := startServer(t)
+	c.command("set key 0 0 1\r\na\r\n", "STORED")
+	c.command("set key 0 -1 1\r\nb\r\n", "STORED")
+	c.command("get key\r\n", "END")
+
+	c.command("set touched 0 0 1\r\na\r\n", "STORED")
+	c.command("touch touched -1\r\n", "TOUCHED")
+	c.command("get touched\r\n", "END")
+	c.command("touch touched -1\r\n", "NOT_FOUND")
+
+	c.command("add fresh 0 -1 1\r\na\r\n", "STORED")
+	c.command("get fresh\r\n", "END")
+}
+
+func TestServeAfterCloseClosesTheListener(t *testing.T) {
+	c := cache.GetCache(map_cache.CreateMapCache(), time.Minute, false)
+	t.Cleanup(c.Close)
+	server := NewServer(c)
+	server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(listener); err != ErrServerClosed {
+		t.Fatalf("Serve after Close = %v, want ErrServerClosed", err)
+	}
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Fatal("listener still accepting after Serve returned")
+	}
+}
+
+func TestOverlongLineIsRejected(t *testing.T) {
+	c, _ := startServer(t)
+	c.command("get "+strings.Repeat("k", maxLineLength)+"\r\n", "CLIENT_ERROR line too long")
+	if _, err := c.rd.ReadString('\n'); err == nil {
+		t.Fatal("connection still open after an overlong line")
+	}
+}
@@ -0,0 +1,238 @@
+package peers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/logger"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBasePath = "/_inmem/peers/"
+	defaultTimeout  = 2 * time.Second
+)
+
+var (
+	ErrUnknownGroup = errors.New("unknown cache group")
+	ErrPeerFetch    = errors.New("peer fetch failed")
+)
+
+var log = logger.Named("peers")
+
+type group struct {
+	cache   *cache.Cache
+	loader  func(key string) (interface{}, error)
+	decoder Decoder
+}
+
+type peerResponse struct {
+	Value interface{} `json:"value"`
+}
+
+type peerRawResponse struct {
+	Value json.RawMessage `json:"value"`
+}
+
+// Decoder turns the json of a value fetched from a peer back into a value. Without type
+// information json gives float64 for numbers and map[string]interface{} for structs, so a peer
+// fetch returns a different type than a local Get unless the group has a Decoder, see DecodeAs.
+type Decoder func(raw json.RawMessage) (interface{}, error)
+
+// DecodeAs decodes peer values into a T, the type the local loader returns
+func DecodeAs[T any]() Decoder {
+	return func(raw json.RawMessage) (interface{}, error) {
+		var value T
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	}
+}
+
+func decodeAny(raw json.RawMessage) (interface{}, error) {
+	var value interface{}
+	err := json.Unmarshal(raw, &value)
+	return value, err
+}
+
+type OptionalGroupConfig func(g *group)
+
+func WithDecoder(decoder Decoder) OptionalGroupConfig {
+	return func(g *group) {
+		g.decoder = decoder
+	}
+}
+
+// Pool makes one node of a statically configured cluster the owner of every key. Loaders
+// returned by Register send misses to the owner over HTTP, so the owner's singleflight dedups
+// the load for the whole cluster, and fall back to the local loader if the owner can't answer.
+type Pool struct {
+	self     string
+	basePath string
+	replicas int
+	peers    []string
+	client   *http.Client
+	ring     *HashRing
+	mu       sync.RWMutex
+	groups   map[string]*group
+}
+
+type OptionalPoolConfig func(p *Pool)
+
+func WithPeers(peers ...string) OptionalPoolConfig {
+	return func(p *Pool) {
+		p.peers = append(p.peers, peers...)
+	}
+}
+
+func WithBasePath(basePath string) OptionalPoolConfig {
+	return func(p *Pool) {
+		p.basePath = basePath
+	}
+}
+
+func WithReplicas(replicas int) OptionalPoolConfig {
+	return func(p *Pool) {
+		p.replicas = replicas
+	}
+}
+
+func WithHTTPClient(client *http.Client) OptionalPoolConfig {
+	return func(p *Pool) {
+		p.client = client
+	}
+}
+
+// NewPool creates the pool for the node reachable at self (e.g. http://10.0.0.1:8080),
+// self is always part of the ring even if it isn't listed in WithPeers
+func NewPool(self string, optionalPoolConfigs ...OptionalPoolConfig) *Pool {
+	pool := &Pool{
+		self:     strings.TrimSuffix(self, "/"),
+		basePath: defaultBasePath,
+		client:   &http.Client{Timeout: defaultTimeout},
+		groups:   make(map[string]*group),
+	}
+	for _, option := range optionalPoolConfigs {
+		option(pool)
+	}
+	if !strings.HasSuffix(pool.basePath, "/") {
+		pool.basePath += "/"
+	}
+	pool.ring = NewHashRing(pool.replicas, nil)
+	nodes := map[string]struct{}{pool.self: {}}
+	for _, peer := range pool.peers {
+		nodes[strings.TrimSuffix(peer, "/")] = struct{}{}
+	}
+	for node := range nodes {
+		pool.ring.Add(node)
+	}
+	return pool
+}
+
+func (p *Pool) BasePath() string {
+	return p.basePath
+}
+
+// Owner returns the base url of the node owning key
+func (p *Pool) Owner(key string) string {
+	return p.ring.Get(key)
+}
+
+// Register exposes c under name to the other nodes and returns the loader to pass to
+// cache.WithLoader, localLoader is only called on the owner or when the owner is unreachable.
+// Values travel as json, pass WithDecoder to get the loader's types back from a peer.
+func (p *Pool) Register(name string, c *cache.Cache, localLoader func(key string) (interface{}, error), optionalGroupConfigs ...OptionalGroupConfig) func(key string) (interface{}, error) {
+	g := &group{cache: c, loader: localLoader, decoder: decodeAny}
+	for _, option := range optionalGroupConfigs {
+		option(g)
+	}
+	p.mu.Lock()
+	p.groups[name] = g
+	p.mu.Unlock()
+	return func(key string) (interface{}, error) {
+		owner := p.Owner(key)
+		if owner == "" || owner == p.self {
+			return localLoader(key)
+		}
+		val, err := p.fetch(owner, name, key, g.decoder)
+		if err != nil {
+			log.Warn("peer fetch failed, loading locally",
+				logger.Err(err),
+				logger.String("key", key),
+				logger.String("peer", owner),
+				logger.String("op", "peerFetch"))
+			return localLoader(key)
+		}
+		return val, nil
+	}
+}
+
+func (p *Pool) fetch(owner string, name string, key string, decoder Decoder) (interface{}, error) {
+	resp, err := p.client.Get(owner + p.basePath + url.PathEscape(name) + "/" + url.PathEscape(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPeerFetch, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %d", ErrPeerFetch, owner, resp.StatusCode)
+	}
+	var body peerRawResponse
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPeerFetch, err)
+	}
+	val, err := decoder(body.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPeerFetch, err)
+	}
+	return val, nil
+}
+
+// ServeHTTP answers GET {basePath}{group}/{key} from the local cache and local loader
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.EscapedPath(), p.basePath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	escapedName, escapedKey, ok := strings.Cut(path, "/")
+	if !ok {
+		http.Error(w, "expected "+p.basePath+"{group}/{key}", http.StatusBadRequest)
+		return
+	}
+	name, err := url.PathUnescape(escapedName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, err := url.PathUnescape(escapedKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.mu.RLock()
+	g, ok := p.groups[name]
+	p.mu.RUnlock()
+	if !ok {
+		http.Error(w, ErrUnknownGroup.Error(), http.StatusNotFound)
+		return
+	}
+	val, err := g.cache.Get(key, cache.WithLoader(g.loader))
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, cache.ErrEntryNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(peerResponse{Value: val})
+}
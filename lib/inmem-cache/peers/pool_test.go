@@ -0,0 +1,174 @@
+package peers
+
+import (
+	"errors"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"inmem/lib/logger"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type node struct {
+	pool   *Pool
+	cache  *cache.Cache
+	loader func(key string) (interface{}, error)
+	loads  atomic.Int32
+}
+
+// newCluster starts n nodes on httptest servers, every node loads key -> len(key) as an int
+func newCluster(t *testing.T, n int, optionalGroupConfigs ...OptionalGroupConfig) []*node {
+	t.Helper()
+	handlers := make([]http.Handler, n)
+	urls := make([]string, n)
+	for i := range handlers {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+	nodes := make([]*node, n)
+	for i := range nodes {
+		nd := &node{
+			pool:  NewPool(urls[i], WithPeers(urls...)),
+			cache: cache.GetCache(map_cache.CreateMapCache(), time.Minute, true),
+		}
+		t.Cleanup(nd.cache.Close)
+		nd.loader = nd.pool.Register("lengths", nd.cache, func(key string) (interface{}, error) {
+			nd.loads.Add(1)
+			return len(key), nil
+		}, optionalGroupConfigs...)
+		handlers[i] = nd.pool
+		nodes[i] = nd
+	}
+	return nodes
+}
+
+// remoteKey finds a key owned by another node than nodes[0]
+func remoteKey(t *testing.T, nodes []*node) (string, *node) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner := nodes[0].pool.Owner(key)
+		for _, nd := range nodes[1:] {
+			if nd.pool.self == owner {
+				return key, nd
+			}
+		}
+	}
+	t.Fatal("no key owned by a peer")
+	return "", nil
+}
+
+func TestMissLoadsOnTheOwner(t *testing.T) {
+	nodes := newCluster(t, 3, WithDecoder(DecodeAs[int]()))
+	key, owner := remoteKey(t, nodes)
+
+	val, err := nodes[0].cache.Get(key, cache.WithLoader(nodes[0].loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != len(key) {
+		t.Fatalf("Get = %#v, want the int %d", val, len(key))
+	}
+	if nodes[0].loads.Load() != 0 || owner.loads.Load() != 1 {
+		t.Fatalf("local loads %d, owner loads %d, want only the owner to load", nodes[0].loads.Load(), owner.loads.Load())
+	}
+}
+
+func TestPeerValuesAreJSONTypedWithoutDecoder(t *testing.T) {
+	nodes := newCluster(t, 2)
+	key, _ := remoteKey(t, nodes)
+
+	val, err := nodes[0].cache.Get(key, cache.WithLoader(nodes[0].loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != float64(len(key)) {
+		t.Fatalf("Get = %#v, want the float64 json decoding gives", val)
+	}
+}
+
+func TestFetchErrorsWrapErrPeerFetch(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	unreachable := server.URL
+	server.Close()
+
+	pool := NewPool("http://self.invalid")
+	if _, err := pool.fetch(unreachable, "lengths", "key", decodeAny); !errors.Is(err, ErrPeerFetch) {
+		t.Fatalf("fetch from a closed server = %v, want ErrPeerFetch", err)
+	}
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer broken.Close()
+	if _, err := pool.fetch(broken.URL, "lengths", "key", decodeAny); !errors.Is(err, ErrPeerFetch) {
+		t.Fatalf("fetch of a malformed body = %v, want ErrPeerFetch", err)
+	}
+}
+
+func TestUnreachableOwnerFallsBackToLocalLoader(t *testing.T) {
+	nodes := newCluster(t, 2)
+	key, _ := remoteKey(t, nodes)
+	nodes[0].pool.client = &http.Client{Transport: failingTransport{}}
+
+	val, err := nodes[0].cache.Get(key, cache.WithLoader(nodes[0].loader))
+	if err != nil || val != len(key) || nodes[0].loads.Load() != 1 {
+		t.Fatalf("Get = %#v, %v with %d local loads, want the local loader's value", val, err, nodes[0].loads.Load())
+	}
+}
+
+func TestFallbackIsLoggedUnderThePeersComponent(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		components map[string]string
+		logged     bool
+	}{
+		{name: "default", logged: true},
+		{name: "peers=error", components: map[string]string{"peers": "error"}, logged: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.log")
+			err := logger.Configure(logger.Config{
+				Level:      string(logger.DEBUG),
+				Components: tc.components,
+				Sinks:      []logger.SinkConfig{{Type: "file", Path: path, Format: "json"}},
+				Async:      logger.AsyncConfig{Disabled: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { logger.Configure(logger.DefaultConfig()) })
+
+			nodes := newCluster(t, 2)
+			key, _ := remoteKey(t, nodes)
+			nodes[0].pool.client = &http.Client{Transport: failingTransport{}}
+			if _, err := nodes[0].cache.Get(key, cache.WithLoader(nodes[0].loader)); err != nil {
+				t.Fatal(err)
+			}
+
+			data, _ := os.ReadFile(path)
+			logged := strings.Contains(string(data), `"op":"peerFetch"`)
+			if logged != tc.logged {
+				t.Fatalf("fallback logged = %v, want %v:\n%s", logged, tc.logged, data)
+			}
+			if logged && (!strings.Contains(string(data), `"component":"peers"`) || !strings.Contains(string(data), `"key":"`+key+`"`)) {
+				t.Fatalf("fallback entry is missing the component or the key:\n%s", data)
+			}
+		})
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
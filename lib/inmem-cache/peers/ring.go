@@ -0,0 +1,62 @@
+package peers
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+const defaultReplicas = 50
+
+type Hash func(data []byte) uint32
+
+// HashRing maps keys to nodes with consistent hashing, every node is placed replicas times on
+// the ring so keys spread evenly and only ~1/n of them move when a node is added.
+type HashRing struct {
+	replicas int
+	hash     Hash
+	points   []uint32
+	nodes    map[uint32]string
+}
+
+func NewHashRing(replicas int, hash Hash) *HashRing {
+	if replicas <= 0 {
+		replicas = defaultReplicas
+	}
+	if hash == nil {
+		hash = crc32.ChecksumIEEE
+	}
+	return &HashRing{
+		replicas: replicas,
+		hash:     hash,
+		nodes:    make(map[uint32]string),
+	}
+}
+
+func (h *HashRing) Add(nodes ...string) {
+	for _, node := range nodes {
+		for i := 0; i < h.replicas; i++ {
+			point := h.hash([]byte(strconv.Itoa(i) + node))
+			h.points = append(h.points, point)
+			h.nodes[point] = node
+		}
+	}
+	sort.Slice(h.points, func(i, j int) bool { return h.points[i] < h.points[j] })
+}
+
+func (h *HashRing) IsEmpty() bool {
+	return len(h.points) == 0
+}
+
+// Get returns the node owning key, the first point clockwise from the key hash
+func (h *HashRing) Get(key string) string {
+	if h.IsEmpty() {
+		return ""
+	}
+	point := h.hash([]byte(key))
+	idx := sort.Search(len(h.points), func(i int) bool { return h.points[i] >= point })
+	if idx == len(h.points) {
+		idx = 0
+	}
+	return h.nodes[h.points[idx]]
+}
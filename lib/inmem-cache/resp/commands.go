@@ -0,0 +1,180 @@
+package resp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errWrongArgs = "ERR wrong number of arguments for '%s' command"
+	errSyntax    = "ERR syntax error"
+	errNotInt    = "ERR value is not an integer or out of range"
+)
+
+// execute runs one command and reports whether the connection should be closed
+func (s *Server) execute(w *writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "PING":
+		s.ping(w, args)
+	case "GET":
+		s.get(w, args)
+	case "SET":
+		s.set(w, args)
+	case "DEL":
+		s.del(w, args)
+	case "EXISTS":
+		s.exists(w, args)
+	case "TTL":
+		s.ttl(w, args)
+	case "INFO":
+		s.info(w)
+	case "COMMAND":
+		// redis-cli asks for command docs on connect, an empty reply keeps it happy
+		w.emptyArray()
+	case "QUIT":
+		w.simple("OK")
+		return true
+	default:
+		w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+func (s *Server) ping(w *writer, args []string) {
+	switch len(args) {
+	case 1:
+		w.simple("PONG")
+	case 2:
+		w.bulk(args[1])
+	default:
+		w.error(fmt.Sprintf(errWrongArgs, "ping"))
+	}
+}
+
+func (s *Server) get(w *writer, args []string) {
+	if len(args) != 2 {
+		w.error(fmt.Sprintf(errWrongArgs, "get"))
+		return
+	}
+	val, err := s.cache.Get(args[1])
+	if err != nil {
+		if errors.Is(err, cache.ErrEntryNotFound) || errors.Is(err, cache.ErrStaleResponse) {
+			w.null()
+			return
+		}
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.bulk(stringify(val))
+}
+
+// SET key value [EX seconds | PX milliseconds]
+func (s *Server) set(w *writer, args []string) {
+	if len(args) != 3 && len(args) != 5 {
+		w.error(fmt.Sprintf(errWrongArgs, "set"))
+		return
+	}
+	var err error
+	if len(args) == 5 {
+		amount, convErr := strconv.ParseInt(args[4], 10, 64)
+		if convErr != nil || amount <= 0 {
+			w.error(errNotInt)
+			return
+		}
+		switch strings.ToUpper(args[3]) {
+		case "EX":
+			err = s.cache.SetWithTTL(args[1], args[2], time.Duration(amount)*time.Second)
+		case "PX":
+			err = s.cache.SetWithTTL(args[1], args[2], time.Duration(amount)*time.Millisecond)
+		default:
+			w.error(errSyntax)
+			return
+		}
+	} else {
+		err = s.cache.Set(args[1], args[2])
+	}
+	if err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.simple("OK")
+}
+
+func (s *Server) del(w *writer, args []string) {
+	if len(args) < 2 {
+		w.error(fmt.Sprintf(errWrongArgs, "del"))
+		return
+	}
+	deletionRes, _ := s.cache.Delete(cache.DeleteWithKeys(args[1:]))
+	if deletionRes == nil {
+		w.integer(0)
+		return
+	}
+	w.integer(int64(len(deletionRes.Success)))
+}
+
+func (s *Server) exists(w *writer, args []string) {
+	if len(args) < 2 {
+		w.error(fmt.Sprintf(errWrongArgs, "exists"))
+		return
+	}
+	var count int64
+	for _, key := range args[1:] {
+		if s.cache.Exists(key) {
+			count++
+		}
+	}
+	w.integer(count)
+}
+
+func (s *Server) ttl(w *writer, args []string) {
+	if len(args) != 2 {
+		w.error(fmt.Sprintf(errWrongArgs, "ttl"))
+		return
+	}
+	remaining, err := s.cache.TTL(args[1])
+	if err != nil {
+		// -2 is how redis says the key does not exist
+		w.integer(-2)
+		return
+	}
+	w.integer(int64((remaining + time.Second/2) / time.Second))
+}
+
+func (s *Server) info(w *writer) {
+	snapshot := s.cache.GetStats().Snapshot()
+	var b strings.Builder
+	b.WriteString("# Stats\r\n")
+	fmt.Fprintf(&b, "keyspace_hits:%d\r\n", snapshot.Hits)
+	fmt.Fprintf(&b, "keyspace_misses:%d\r\n", snapshot.Misses)
+	fmt.Fprintf(&b, "hit_ratio:%.2f\r\n", snapshot.HitRatio)
+	fmt.Fprintf(&b, "evicted_keys:%d\r\n", snapshot.Evictions)
+	fmt.Fprintf(&b, "stale_served:%d\r\n", snapshot.StaleServed)
+	fmt.Fprintf(&b, "delete_hits:%d\r\n", snapshot.DeleteHits)
+	fmt.Fprintf(&b, "delete_misses:%d\r\n", snapshot.DeleteMisses)
+	fmt.Fprintf(&b, "tag_invalidations:%d\r\n", snapshot.TagInvalidations)
+	fmt.Fprintf(&b, "load_count:%d\r\n", snapshot.LoadCount)
+	fmt.Fprintf(&b, "avg_load_time_ms:%.4f\r\n", snapshot.AvgLoadTime)
+	fmt.Fprintf(&b, "total_entries:%d\r\n", snapshot.TotalEntries)
+	b.WriteString("\r\n# Keyspace\r\n")
+	fmt.Fprintf(&b, "db0:keys=%d\r\n", snapshot.LiveEntries)
+	w.bulk(b.String())
+}
+
+func stringify(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
@@ -0,0 +1,136 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxArgs and maxLineLength are the redis limits for the number of arguments of a command
+	// and the length of an inline command or a header line
+	maxArgs              = 1024 * 1024
+	maxLineLength        = 64 * 1024
+	defaultMaxBulkLength = 16 * 1024 * 1024
+	// defaultMaxQueryLength bounds all the arguments of one command together, like redis'
+	// client-query-buffer-limit, so a client can't make the server buffer maxArgs full size bulks
+	defaultMaxQueryLength = 64 * 1024 * 1024
+	// maxPreallocatedArgs is how many arguments readCommand reserves room for before they arrive
+	maxPreallocatedArgs = 1024
+)
+
+var (
+	ErrProtocol = errors.New("protocol error")
+)
+
+// reader never allocates more than the limits allow up front, counts and sizes come from the client
+type reader struct {
+	rd             *bufio.Reader
+	maxBulkLength  int
+	maxQueryLength int
+}
+
+// readCommand reads either a RESP array of bulk strings (what clients send) or an inline
+// command (what you type into telnet)
+func (r *reader) readCommand() ([]string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+	}
+	args := make([]string, 0, min(count, maxPreallocatedArgs))
+	remaining := r.maxQueryLength
+	for i := 0; i < count; i++ {
+		arg, err := r.readBulk(remaining)
+		if err != nil {
+			return nil, err
+		}
+		remaining -= len(arg)
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readBulk reads one bulk string, remaining is what is left of the query length for the command
+func (r *reader) readBulk(remaining int) (string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return "", err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return "", fmt.Errorf("%w: expected '$'", ErrProtocol)
+	}
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 || size > r.maxBulkLength {
+		return "", fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+	}
+	if size > remaining {
+		return "", fmt.Errorf("%w: query too long", ErrProtocol)
+	}
+	buf := make([]byte, size+2)
+	if _, err = io.ReadFull(r.rd, buf); err != nil {
+		return "", err
+	}
+	if buf[size] != '\r' || buf[size+1] != '\n' {
+		return "", fmt.Errorf("%w: bulk not terminated by CRLF", ErrProtocol)
+	}
+	return string(buf[:size]), nil
+}
+
+func (r *reader) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLength {
+			return "", fmt.Errorf("%w: line too long", ErrProtocol)
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+type writer struct {
+	wr *bufio.Writer
+}
+
+func (w *writer) simple(s string) {
+	w.wr.WriteString("+" + s + "\r\n")
+}
+
+func (w *writer) error(s string) {
+	w.wr.WriteString("-" + s + "\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.wr.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w *writer) bulk(s string) {
+	w.wr.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func (w *writer) null() {
+	w.wr.WriteString("$-1\r\n")
+}
+
+func (w *writer) emptyArray() {
+	w.wr.WriteString("*0\r\n")
+}
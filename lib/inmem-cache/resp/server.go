@@ -0,0 +1,159 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/logger"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrServerClosed = errors.New("resp server closed")
+
+var log = logger.Named("resp")
+
+// Server speaks the subset of the redis protocol listed in commands.go on top of a Cache,
+// so redis-cli and non-Go services can share the in-memory store
+type Server struct {
+	cache          *cache.Cache
+	maxBulkLength  int
+	maxQueryLength int
+	mu             sync.Mutex
+	listener       net.Listener
+	conns          map[net.Conn]struct{}
+	closed         atomic.Bool
+	wg             sync.WaitGroup
+}
+
+type OptionalServerConfig func(s *Server)
+
+// WithMaxBulkLength caps the size of a single argument, SET values included, the default is 16MB
+func WithMaxBulkLength(maxBulkLength int) OptionalServerConfig {
+	return func(s *Server) {
+		if maxBulkLength > 0 {
+			s.maxBulkLength = maxBulkLength
+		}
+	}
+}
+
+// WithMaxQueryLength caps the size of all the arguments of one command together, the default is 64MB
+func WithMaxQueryLength(maxQueryLength int) OptionalServerConfig {
+	return func(s *Server) {
+		if maxQueryLength > 0 {
+			s.maxQueryLength = maxQueryLength
+		}
+	}
+}
+
+func NewServer(c *cache.Cache, optionalServerConfigs ...OptionalServerConfig) *Server {
+	server := &Server{
+		cache:          c,
+		maxBulkLength:  defaultMaxBulkLength,
+		maxQueryLength: defaultMaxQueryLength,
+		conns:          make(map[net.Conn]struct{}),
+	}
+	for _, option := range optionalServerConfigs {
+		option(server)
+	}
+	return server
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections until Close is called, it always returns a non nil error
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed.Load() {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.closed.Load() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		// Close may have run between Accept and here, it won't see conn in s.conns then
+		s.mu.Lock()
+		if s.closed.Load() {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+	r := &reader{rd: bufio.NewReader(conn), maxBulkLength: s.maxBulkLength, maxQueryLength: s.maxQueryLength}
+	w := &writer{wr: bufio.NewWriter(conn)}
+	for {
+		args, err := r.readCommand()
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				w.error("ERR " + err.Error())
+				w.wr.Flush()
+			} else if !errors.Is(err, io.EOF) && !s.closed.Load() {
+				log.Warn("resp read failed",
+					logger.Err(err),
+					logger.String("remote", conn.RemoteAddr().String()),
+					logger.String("op", "respRead"))
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(w, args)
+		// pipelined commands are answered in one write
+		if r.rd.Buffered() == 0 || quit {
+			if err = w.wr.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
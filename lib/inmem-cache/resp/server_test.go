@@ -0,0 +1,133 @@
+package resp
+
+import (
+	"bufio"
+	cache "inmem/lib/inmem-cache"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+func startServer(t *testing.T, optionalServerConfigs ...OptionalServerConfig) *client {
+	t.Helper()
+	c := cache.GetCache(map_cache.CreateMapCache(), time.Minute, true)
+	t.Cleanup(c.Close)
+	server := NewServer(c, optionalServerConfigs...)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{t: t, conn: conn, rd: bufio.NewReader(conn)}
+}
+
+func (c *client) send(raw string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(raw)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// reply reads one reply, bulk replies come back as their payload
+func (c *client) reply() string {
+	c.t.Helper()
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "$") && line != "$-1" {
+		payload, err := c.rd.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		return strings.TrimRight(payload, "\r\n")
+	}
+	return line
+}
+
+func (c *client) command(raw string, want string) {
+	c.t.Helper()
+	c.send(raw)
+	if got := c.reply(); got != want {
+		c.t.Fatalf("%q replied %q, want %q", raw, got, want)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	c := startServer(t)
+	c.command("PING\r\n", "+PONG")
+	c.command("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n", "+OK")
+	c.command("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", "value")
+	c.command("EXISTS key missing\r\n", ":1")
+	c.command("SET short v EX 10\r\n", "+OK")
+	c.command("TTL short\r\n", ":10")
+	c.command("TTL missing\r\n", ":-2")
+	c.command("DEL key short missing\r\n", ":2")
+	c.command("GET key\r\n", "$-1")
+}
+
+func TestOversizedMultibulkIsRejected(t *testing.T) {
+	c := startServer(t)
+	// preallocating from this count used to abort the process with out of memory
+	c.command("*999999999999\r\n", "-ERR protocol error: invalid multibulk length")
+}
+
+func TestLargeArgCountIsNotPreallocated(t *testing.T) {
+	c := startServer(t)
+	c.send("*1048576\r\n$4\r\nPING\r\n")
+	// the server waits for the remaining arguments instead of allocating them, closing is the answer
+	c.conn.(*net.TCPConn).CloseWrite()
+	if _, err := c.rd.ReadString('\n'); err == nil {
+		t.Fatal("got a reply to an incomplete command")
+	}
+}
+
+func TestOversizedBulkIsRejected(t *testing.T) {
+	c := startServer(t, WithMaxBulkLength(8))
+	c.command("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$9\r\n", "-ERR protocol error: invalid bulk length")
+}
+
+func TestOversizedQueryIsRejected(t *testing.T) {
+	c := startServer(t, WithMaxBulkLength(8), WithMaxQueryLength(12))
+	c.command("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$6\r\nvalue1\r\n", "+OK")
+	c = startServer(t, WithMaxBulkLength(8), WithMaxQueryLength(12))
+	c.command("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$7\r\n", "-ERR protocol error: query too long")
+}
+
+func TestServeAfterCloseClosesTheListener(t *testing.T) {
+	c := cache.GetCache(map_cache.CreateMapCache(), time.Minute, false)
+	t.Cleanup(c.Close)
+	server := NewServer(c)
+	server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(listener); err != ErrServerClosed {
+		t.Fatalf("Serve after Close = %v, want ErrServerClosed", err)
+	}
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Fatal("listener still accepting after Serve returned")
+	}
+}
+
+func TestOverlongLineIsRejected(t *testing.T) {
+	c := startServer(t)
+	c.command("GET "+strings.Repeat("k", maxLineLength)+"\r\n", "-ERR protocol error: line too long")
+}
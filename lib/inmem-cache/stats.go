@@ -1,8 +1,9 @@
 package inmem_cache
 
 import (
-	"fmt"
+	"inmem/lib/inmem-cache/clock"
 	"inmem/lib/logger"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -19,6 +20,10 @@ type CacheStats struct {
 	tagInvalidations atomic.Int32
 	deleteHits       atomic.Int32
 	deleteMisses     atomic.Int32
+	clock            clock.Clock
+	log              *logger.Logger
+	stop             chan struct{}
+	stopOnce         sync.Once
 }
 
 func (c *CacheStats) Hit() {
@@ -70,69 +75,128 @@ func (c *CacheStats) Reset() {
 }
 
 func InitStats() *CacheStats {
-	cacheStats := new(CacheStats)
+	return InitStatsWithClock(clock.Real())
+}
+
+func InitStatsWithClock(clock clock.Clock) *CacheStats {
+	return initStats(clock, logger.Named("cache"))
+}
+
+func initStats(clock clock.Clock, log *logger.Logger) *CacheStats {
+	cacheStats := &CacheStats{clock: clock, log: log, stop: make(chan struct{})}
 	go cacheStats.LogStats()
 	return cacheStats
 }
 
-func (c *CacheStats) LogStats() {
-	tick := time.Tick(time.Second * 5)
-	for range tick {
-		hits := c.hit.Load()
-		misses := c.miss.Load()
-		totalRequests := hits + misses
-
-		deleteHits := c.deleteHits.Load()
-		deleteMisses := c.deleteMisses.Load()
-		totalDeletes := deleteHits + deleteMisses
-
-		totalEntriesCount := c.entriesCount.Load()
-		loadCount := c.loadCount.Load()
-		totalLoadTime := c.loadTime.Load()
-
-		liveEntries := totalEntriesCount - deleteHits
-
-		// Hit Ratio
-		var hitRatio float64
-		if totalRequests > 0 {
-			hitRatio = (float64(hits) / float64(totalRequests)) * 100
-		}
-
-		// Delete Hit Ratio
-		var deleteHitRatio float64
-		if totalDeletes > 0 {
-			deleteHitRatio = (float64(deleteHits) / float64(totalDeletes)) * 100
-		}
+// Stop ends LogStats, the counters keep working
+func (c *CacheStats) Stop() {
+	if c == nil || c.stop == nil {
+		return
+	}
+	c.stopOnce.Do(func() { close(c.stop) })
+}
 
-		// Avg Load Time
-		var avgLoadTime float64
-		if loadCount > 0 {
-			avgLoadTime = float64(totalLoadTime) / float64(loadCount)
-		}
+type StatsSnapshot struct {
+	Hits             int32   `json:"hits"`
+	Misses           int32   `json:"misses"`
+	HitRatio         float64 `json:"hit_ratio"`
+	DeleteHits       int32   `json:"delete_hits"`
+	DeleteMisses     int32   `json:"delete_misses"`
+	DeleteHitRatio   float64 `json:"delete_hit_ratio"`
+	TotalLoadTime    int64   `json:"total_load_time"`
+	LoadCount        int32   `json:"load_count"`
+	AvgLoadTime      float64 `json:"avg_load_time"`
+	LiveEntries      int32   `json:"live_entries"`
+	TotalEntries     int32   `json:"total_entries"`
+	Evictions        int32   `json:"evictions"`
+	TagInvalidations int32   `json:"tag_invalidations"`
+	StaleServed      int32   `json:"stale_served"`
+	MemoryUsage      int64   `json:"memory_usage"`
+}
 
-		fields := map[string]string{
-			"hits":      fmt.Sprintf("%d", hits),
-			"misses":    fmt.Sprintf("%d", misses),
-			"hit_ratio": fmt.Sprintf("%.2f", hitRatio),
+func (c *CacheStats) Snapshot() StatsSnapshot {
+	if c == nil {
+		return StatsSnapshot{}
+	}
+	hits := c.hit.Load()
+	misses := c.miss.Load()
+	totalRequests := hits + misses
+
+	deleteHits := c.deleteHits.Load()
+	deleteMisses := c.deleteMisses.Load()
+	totalDeletes := deleteHits + deleteMisses
+
+	totalEntriesCount := c.entriesCount.Load()
+	loadCount := c.loadCount.Load()
+	totalLoadTime := c.loadTime.Load()
+
+	snapshot := StatsSnapshot{
+		Hits:             hits,
+		Misses:           misses,
+		DeleteHits:       deleteHits,
+		DeleteMisses:     deleteMisses,
+		TotalLoadTime:    totalLoadTime,
+		LoadCount:        loadCount,
+		LiveEntries:      totalEntriesCount - deleteHits,
+		TotalEntries:     totalEntriesCount,
+		Evictions:        c.evictions.Load(),
+		TagInvalidations: c.tagInvalidations.Load(),
+		StaleServed:      c.staleServe.Load(),
+		MemoryUsage:      c.memoryUsage.Load(),
+	}
 
-			"delete_hits":      fmt.Sprintf("%d", deleteHits),
-			"delete_misses":    fmt.Sprintf("%d", deleteMisses),
-			"delete_hit_ratio": fmt.Sprintf("%.2f", deleteHitRatio),
+	// Hit Ratio
+	if totalRequests > 0 {
+		snapshot.HitRatio = (float64(hits) / float64(totalRequests)) * 100
+	}
 
-			"total_load_time": fmt.Sprintf("%d", totalLoadTime),
-			"load_count":      fmt.Sprintf("%d", loadCount),
-			"avg_load_time":   fmt.Sprintf("%.4f", avgLoadTime),
+	// Delete Hit Ratio
+	if totalDeletes > 0 {
+		snapshot.DeleteHitRatio = (float64(deleteHits) / float64(totalDeletes)) * 100
+	}
 
-			"live_entries":  fmt.Sprintf("%d", liveEntries),
-			"total_entries": fmt.Sprintf("%d", totalEntriesCount),
+	// Avg Load Time
+	if loadCount > 0 {
+		snapshot.AvgLoadTime = float64(totalLoadTime) / float64(loadCount)
+	}
+	return snapshot
+}
 
-			"evictions":         fmt.Sprintf("%d", c.evictions.Load()),
-			"tag_invalidations": fmt.Sprintf("%d", c.tagInvalidations.Load()),
-			"stale_served":      fmt.Sprintf("%d", c.staleServe.Load()),
+func (c *CacheStats) LogStats() {
+	if c.log == nil {
+		c.log = logger.Named("cache")
+	}
+	ticker := c.clock.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C():
 		}
-
-		logger.Dispatch(logger.DEBUG, logger.WithEntry().
-			WithFieldMap(fields).
-			WithMessage("cache stats"))
+		if !c.log.Enabled(logger.DEBUG) {
+			continue
+		}
+		snapshot := c.Snapshot()
+		c.log.Debug("cache stats",
+			logger.Int64("hits", int64(snapshot.Hits)),
+			logger.Int64("misses", int64(snapshot.Misses)),
+			logger.Float("hit_ratio", snapshot.HitRatio),
+
+			logger.Int64("delete_hits", int64(snapshot.DeleteHits)),
+			logger.Int64("delete_misses", int64(snapshot.DeleteMisses)),
+			logger.Float("delete_hit_ratio", snapshot.DeleteHitRatio),
+
+			logger.Int64("total_load_time", int64(snapshot.TotalLoadTime)),
+			logger.Int64("load_count", int64(snapshot.LoadCount)),
+			logger.Float("avg_load_time", snapshot.AvgLoadTime),
+
+			logger.Int64("live_entries", int64(snapshot.LiveEntries)),
+			logger.Int64("total_entries", int64(snapshot.TotalEntries)),
+
+			logger.Int64("evictions", int64(snapshot.Evictions)),
+			logger.Int64("tag_invalidations", int64(snapshot.TagInvalidations)),
+			logger.Int64("stale_served", int64(snapshot.StaleServed)),
+		)
 	}
 }
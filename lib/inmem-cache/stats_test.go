@@ -0,0 +1,28 @@
+package inmem_cache
+
+import (
+	"inmem/lib/inmem-cache/clock/fakeclock"
+	"inmem/lib/logger"
+	"testing"
+	"time"
+)
+
+func TestStatsStopEndsLogStats(t *testing.T) {
+	stats := &CacheStats{
+		clock: fakeclock.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		log:   logger.Named("cache"),
+		stop:  make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		stats.LogStats()
+		close(done)
+	}()
+	stats.Stop()
+	stats.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("LogStats still running after Stop")
+	}
+}
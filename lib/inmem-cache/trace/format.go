@@ -0,0 +1,156 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// Trace files start with magic, then the absolute start time in unix nanos. Every record is
+// a varint nanosecond delta from the previous record, the op byte, the key and the value size.
+const magic = "INMTRC01"
+
+var (
+	ErrBadMagic  = errors.New("not a cache trace file")
+	ErrBadOp     = errors.New("unknown trace op")
+	ErrCacheNil  = errors.New("cache is nil")
+	ErrNoFactory = errors.New("no cache factory to replay against")
+)
+
+type Op byte
+
+const (
+	OpGet Op = iota + 1
+	OpSet
+	OpDelete
+	// OpLoad records the size a loader produced after a miss, replay uses it to size the mock load
+	OpLoad
+	// OpLoadSet is the set that stored a loader result, replay skips it since the replayed get loads itself
+	OpLoadSet
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpGet:
+		return "get"
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	case OpLoad:
+		return "load"
+	case OpLoadSet:
+		return "loadset"
+	default:
+		return "unknown"
+	}
+}
+
+type Record struct {
+	Time time.Time
+	Op   Op
+	Key  string
+	Size int
+}
+
+type Writer struct {
+	w    *bufio.Writer
+	last int64
+	buf  [binary.MaxVarintLen64]byte
+}
+
+func NewWriter(w io.Writer, start time.Time) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(magic); err != nil {
+		return nil, err
+	}
+	tw := &Writer{w: bw, last: start.UnixNano()}
+	if err := tw.varint(tw.last); err != nil {
+		return nil, err
+	}
+	return tw, nil
+}
+
+func (w *Writer) Write(record Record) error {
+	now := record.Time.UnixNano()
+	// events arrive from several dispatcher workers, so deltas can be slightly negative
+	if err := w.varint(now - w.last); err != nil {
+		return err
+	}
+	w.last = now
+	if err := w.w.WriteByte(byte(record.Op)); err != nil {
+		return err
+	}
+	if err := w.uvarint(uint64(len(record.Key))); err != nil {
+		return err
+	}
+	if _, err := w.w.WriteString(record.Key); err != nil {
+		return err
+	}
+	return w.uvarint(uint64(record.Size))
+}
+
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) varint(v int64) error {
+	n := binary.PutVarint(w.buf[:], v)
+	_, err := w.w.Write(w.buf[:n])
+	return err
+}
+
+func (w *Writer) uvarint(v uint64) error {
+	n := binary.PutUvarint(w.buf[:], v)
+	_, err := w.w.Write(w.buf[:n])
+	return err
+}
+
+type Reader struct {
+	r    *bufio.Reader
+	last int64
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, header); err != nil || string(header) != magic {
+		return nil, ErrBadMagic
+	}
+	start, err := binary.ReadVarint(br)
+	if err != nil {
+		return nil, ErrBadMagic
+	}
+	return &Reader{r: br, last: start}, nil
+}
+
+// Next returns the next record, io.EOF once the trace is exhausted
+func (r *Reader) Next() (Record, error) {
+	delta, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return Record{}, err
+	}
+	op, err := r.r.ReadByte()
+	if err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	if Op(op) < OpGet || Op(op) > OpLoadSet {
+		return Record{}, ErrBadOp
+	}
+	keyLen, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(r.r, key); err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	r.last += delta
+	return Record{Time: time.Unix(0, r.last), Op: Op(op), Key: string(key), Size: int(size)}, nil
+}
@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"encoding/json"
+	cache "inmem/lib/inmem-cache"
+	"io"
+	"sync"
+	"time"
+)
+
+// Recorder writes the accesses of a cache to a trace through its event hooks. Events are
+// delivered asynchronously and dropped when the dispatcher queue is full, see EventDispatcher.Dropped.
+type Recorder struct {
+	mu     sync.Mutex
+	writer *Writer
+	closer io.Closer
+	closed bool
+	err    error
+}
+
+// NewRecorder starts a trace on w, Close also closes w when it is an io.Closer
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	writer, err := NewWriter(w, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{writer: writer}
+	if closer, ok := w.(io.Closer); ok {
+		r.closer = closer
+	}
+	return r, nil
+}
+
+// Attach subscribes the recorder to c, listeners can't be removed so Close just stops writing
+func (r *Recorder) Attach(c *cache.Cache) error {
+	if c == nil {
+		return ErrCacheNil
+	}
+	c.OnHit(func(event cache.CacheEvent) {
+		r.record(event.Time, OpGet, event.Key, valueSize(event.Value))
+	})
+	c.OnMiss(func(event cache.CacheEvent) {
+		r.record(event.Time, OpGet, event.Key, 0)
+	})
+	c.OnStale(func(event cache.CacheEvent) {
+		r.record(event.Time, OpGet, event.Key, valueSize(event.Value))
+	})
+	c.OnLoad(func(event cache.CacheEvent) {
+		r.record(event.Time, OpLoad, event.Key, valueSize(event.Value))
+	})
+	c.OnSet(func(event cache.CacheEvent) {
+		op := OpSet
+		if event.Loaded {
+			op = OpLoadSet
+		}
+		r.record(event.Time, op, event.Key, valueSize(event.Value))
+	})
+	c.OnEvict(func(event cache.CacheEvent) {
+		// expiry and capacity evictions are outcomes of the cache config, not part of the workload
+		if event.Reason == cache.EvictDeleted || event.Reason == cache.EvictTag {
+			r.record(event.Time, OpDelete, event.Key, 0)
+		}
+	})
+	return nil
+}
+
+func (r *Recorder) record(at time.Time, op Op, key string, size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.err != nil {
+		return
+	}
+	r.err = r.writer.Write(Record{Time: at, Op: op, Key: key, Size: size})
+}
+
+// Close flushes the trace and returns the first write error, if any
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return r.err
+	}
+	r.closed = true
+	if err := r.writer.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if r.closer != nil {
+		if err := r.closer.Close(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
+
+func valueSize(value interface{}) int {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
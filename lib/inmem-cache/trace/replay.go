@@ -0,0 +1,175 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/clock"
+	"inmem/lib/inmem-cache/clock/fakeclock"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultLoadSize sizes mock loads for keys the trace never saw a value for
+const defaultLoadSize = 64
+
+// Factory builds the cache configuration under test. Replay drives the given clock from the
+// trace timestamps, so the cache must use it (cache.WithClock) for TTLs to behave like production.
+type Factory func(clock clock.Clock) *cache.Cache
+
+type Result struct {
+	Name     string        `json:"name"`
+	Ops      int           `json:"ops"`
+	Gets     int           `json:"gets"`
+	Hits     int           `json:"hits"`
+	Misses   int           `json:"misses"`
+	HitRatio float64       `json:"hit_ratio"`
+	Sets     int           `json:"sets"`
+	Deletes  int           `json:"deletes"`
+	Span     time.Duration `json:"span"`
+	Elapsed  time.Duration `json:"elapsed"`
+	// HeapDelta is the live heap retained by the cache after the replay, measured around a GC
+	HeapDelta   int64 `json:"heap_delta"`
+	LiveEntries int32 `json:"live_entries"`
+}
+
+func (r *Result) String() string {
+	return fmt.Sprintf("%-20s ops=%d gets=%d hits=%d misses=%d hit_ratio=%.2f%% sets=%d deletes=%d heap=%s live=%d",
+		r.Name, r.Ops, r.Gets, r.Hits, r.Misses, r.HitRatio, r.Sets, r.Deletes, formatBytes(r.HeapDelta), r.LiveEntries)
+}
+
+// Replay runs every record of the trace against a fresh cache from factory, as fast as possible
+// but with the cache clock following the recorded timestamps
+func Replay(r io.Reader, name string, factory Factory) (*Result, error) {
+	if factory == nil {
+		return nil, ErrNoFactory
+	}
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	heapBefore := liveHeap()
+	fake := fakeclock.New(time.Unix(0, reader.last))
+	c := factory(fake)
+	if c == nil {
+		return nil, ErrCacheNil
+	}
+	defer c.Close()
+
+	result := &Result{Name: name}
+	sizes := make(map[string]int)
+	start := time.Now()
+	first, last := time.Time{}, time.Time{}
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first.IsZero() {
+			first = record.Time
+		}
+		if record.Time.After(fake.Now()) {
+			fake.Set(record.Time)
+			last = record.Time
+		}
+		result.Ops++
+		switch record.Op {
+		case OpGet:
+			result.Gets++
+			loaded := false
+			_, err = c.Get(record.Key, cache.WithLoader(func(key string) (interface{}, error) {
+				loaded = true
+				size, ok := sizes[key]
+				if !ok {
+					size = defaultLoadSize
+				}
+				return strings.Repeat("x", size), nil
+			}))
+			if loaded || err != nil {
+				result.Misses++
+			} else {
+				result.Hits++
+			}
+		case OpLoad, OpLoadSet:
+			sizes[record.Key] = record.Size
+		case OpSet:
+			result.Sets++
+			sizes[record.Key] = record.Size
+			c.Set(record.Key, strings.Repeat("x", record.Size))
+		case OpDelete:
+			result.Deletes++
+			c.Delete(cache.DeleteWithKeys([]string{record.Key}))
+		}
+	}
+	result.Elapsed = time.Since(start)
+	if !last.IsZero() {
+		result.Span = last.Sub(first)
+	}
+	if result.Gets > 0 {
+		result.HitRatio = float64(result.Hits) / float64(result.Gets) * 100
+	}
+	result.LiveEntries = c.GetStats().Snapshot().LiveEntries
+	result.HeapDelta = liveHeap() - heapBefore
+	runtime.KeepAlive(c)
+	return result, nil
+}
+
+// Compare replays the trace file once per factory, results are sorted by name
+func Compare(path string, factories map[string]Factory) ([]*Result, error) {
+	if len(factories) == 0 {
+		return nil, ErrNoFactory
+	}
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	results := make([]*Result, 0, len(names))
+	for _, name := range names {
+		result, err := replayFile(path, name, factories[name])
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func replayFile(path string, name string, factory Factory) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Replay(file, name, factory)
+}
+
+func liveHeap() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit && n > -unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, suffix := float64(n), ""
+	for _, s := range []string{"KiB", "MiB", "GiB"} {
+		value /= unit
+		suffix = s
+		if value < unit && value > -unit {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
@@ -0,0 +1,125 @@
+package trace
+
+import (
+	"bytes"
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/clock"
+	"inmem/lib/inmem-cache/clock/fakeclock"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"io"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, trace []byte) []Record {
+	t.Helper()
+	reader, err := NewReader(bytes.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []Record
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+// record runs a miss that loads, a stale read, a caller set, a hit and a delete through a recorded cache
+func record(t *testing.T) []byte {
+	t.Helper()
+	clk := fakeclock.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := cache.GetCache(map_cache.CreateMapCache(), time.Minute, true, cache.WithClock(clk))
+	var trace bytes.Buffer
+	recorder, err := NewRecorder(&trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Attach(c)
+
+	loader := cache.WithLoader(func(key string) (interface{}, error) { return "loaded", nil })
+	c.Get("loaded", loader)
+	clk.Advance(2 * time.Minute)
+	c.Get("loaded")
+	c.Set("written", "value")
+	c.Get("written")
+	c.Delete(cache.DeleteWithKeys([]string{"written"}))
+	// Close delivers the queued events before the recorder stops writing
+	c.Close()
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return trace.Bytes()
+}
+
+func TestRecorderMarksLoaderSets(t *testing.T) {
+	counts := make(map[Op]int)
+	for _, record := range readAll(t, record(t)) {
+		counts[record.Op]++
+	}
+	want := map[Op]int{OpGet: 3, OpLoad: 1, OpLoadSet: 1, OpSet: 1, OpDelete: 1}
+	for op, count := range want {
+		if counts[op] != count {
+			t.Fatalf("%d %s records, want %d: %v", counts[op], op, count, counts)
+		}
+	}
+}
+
+func TestReplaySkipsLoaderSets(t *testing.T) {
+	var replayed *cache.Cache
+	result, err := Replay(bytes.NewReader(record(t)), "map", func(clk clock.Clock) *cache.Cache {
+		replayed = cache.GetCache(map_cache.CreateMapCache(), time.Minute, true, cache.WithClock(clk))
+		return replayed
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sets != 1 {
+		t.Fatalf("replayed %d sets, want only the caller's set", result.Sets)
+	}
+	if result.Gets != 3 || result.Deletes != 1 {
+		t.Fatalf("result = %+v, want 3 gets and 1 delete", result)
+	}
+	if entries := replayed.GetStats().Snapshot().TotalEntries; entries != 2 {
+		t.Fatalf("replay stored %d entries, want the load and the caller's set", entries)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Time: start.Add(time.Second), Op: OpSet, Key: "key", Size: 12},
+		// out of order by a little, like events from several dispatcher workers
+		{Time: start.Add(time.Second - time.Millisecond), Op: OpGet, Key: "key"},
+		{Time: start.Add(time.Minute), Op: OpLoadSet, Key: "other", Size: 3},
+	}
+	for _, record := range want {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writer.Flush()
+	got := readAll(t, buf.Bytes())
+	if len(got) != len(want) {
+		t.Fatalf("read %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].Op != want[i].Op || got[i].Key != want[i].Key || got[i].Size != want[i].Size {
+			t.Fatalf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("not a trace"))); !errors.Is(err, ErrBadMagic) {
+		t.Fatalf("NewReader = %v, want ErrBadMagic", err)
+	}
+}
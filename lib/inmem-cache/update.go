@@ -0,0 +1,170 @@
+package inmem_cache
+
+import (
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	keyLockStripes   = 256
+	maxUpdateRetries = 16
+)
+
+// keyLocks serializes the read-modify-write operations of a Cache per key stripe
+type keyLocks struct {
+	stripes [keyLockStripes]sync.Mutex
+}
+
+func (k *keyLocks) lock(key string) func() {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	stripe := &k.stripes[hash.Sum32()%keyLockStripes]
+	stripe.Lock()
+	return stripe.Unlock
+}
+
+// Update replaces the value of key with fn(old) atomically, old is nil when the key is
+// absent or stale. fn runs under the key lock and must not call back into the Cache.
+// Update, GetOrSet and Increment serialize on a striped key lock, plain Set does not take
+// it. Adaptors implementing CompareAndSwapContract still guard against a concurrent Set by
+// retrying, so fn can run more than once and has to be free of side effects. Without
+// CompareAndSwapContract a concurrent Set can be overwritten.
+func (c *Cache) Update(key string, fn func(old any) (any, error)) (res any, err error) {
+	defer c.logUpdateError(key, UPDATE, &err)
+	res, err = c.update(key, c.ttl, func(old any, _ bool) (any, error) {
+		return fn(old)
+	})
+	if err == nil {
+		c.recordSet(key, res)
+	}
+	return res, err
+}
+
+// GetOrSet returns the cached value or stores and returns fn(), concurrent callers for
+// the same key wait for the first one instead of calling fn again
+func (c *Cache) GetOrSet(key string, fn func() (any, error)) (res any, err error) {
+	defer c.logUpdateError(key, GETORSET, &err)
+	if val, _, found := c.read(key); found {
+		c.stats.Hit()
+		c.events.Emit(CacheEvent{Type: EventHit, Key: key, Value: val})
+		return val, nil
+	}
+	c.stats.Miss()
+	c.events.Emit(CacheEvent{Type: EventMiss, Key: key})
+	unlock := c.keyLocks.lock(key)
+	defer unlock()
+	if val, _, found := c.read(key); found {
+		return val, nil
+	}
+	res, err = fn()
+	if err != nil {
+		return nil, err
+	}
+	cacheEntry := c.newEntry(res, c.ttl)
+	err = c.swap(key, 0, cacheEntry)
+	if errors.Is(err, ErrCompareAndSwapUnsupported) {
+		err = c.cacheAdaptor.Set(key, cacheEntry)
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		// a plain Set won the race, its value is the one to return
+		if val, _, found := c.read(key); found {
+			return val, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.recordSet(key, res)
+	return res, nil
+}
+
+// Increment adds delta to a numeric value, a missing key starts from 0.
+// The entry gets the cache wide ttl again, like every other write.
+func (c *Cache) Increment(key string, delta int64) (res int64, err error) {
+	defer c.logUpdateError(key, INCREMENT, &err)
+	val, err := c.update(key, c.ttl, func(old any, found bool) (any, error) {
+		if !found {
+			return delta, nil
+		}
+		current, err := toInt64(old)
+		if err != nil {
+			return nil, err
+		}
+		return current + delta, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	c.recordSet(key, val)
+	return val.(int64), nil
+}
+
+// update must not record stats for the write, SoftDelete goes through it as well
+func (c *Cache) update(key string, ttl time.Duration, fn func(old any, found bool) (any, error)) (any, error) {
+	unlock := c.keyLocks.lock(key)
+	defer unlock()
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		old, version, found := c.read(key)
+		newValue, err := fn(old, found)
+		if err != nil {
+			return nil, err
+		}
+		cacheEntry := c.newEntry(newValue, ttl)
+		err = c.swap(key, version, cacheEntry)
+		if errors.Is(err, ErrCompareAndSwapUnsupported) {
+			// the stripe lock is all the atomicity the adaptor gives us
+			err = c.cacheAdaptor.Set(key, cacheEntry)
+		}
+		if errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrEntryNotFound) {
+			continue
+		}
+		return newValue, err
+	}
+	return nil, ErrVersionConflict
+}
+
+// read returns the live value of key without touching stats or loaders
+func (c *Cache) read(key string) (any, uint64, bool) {
+	val, err := c.cacheAdaptor.Get(key)
+	if err != nil || val.isInValidEntry(c.clock.Now(), 0) {
+		return nil, 0, false
+	}
+	return val.Value, val.Version, true
+}
+
+func (c *Cache) logUpdateError(key string, op CacheOperation, err *error) {
+	if *err == nil {
+		return
+	}
+	*err = cacheError(op, key, *err)
+	// a missing key is an expected outcome, like deleting an absent key
+	if !errors.Is(*err, ErrEntryNotFound) {
+		c.logError(op, key, *err)
+	}
+}
+
+// toInt64 also accepts float64 and strings, serializing adaptors and the text protocols hand those back
+func toInt64(val any) (int64, error) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint32:
+		return int64(v), nil
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v), nil
+		}
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, ErrNotNumeric
+}
@@ -0,0 +1,91 @@
+package inmem_cache_test
+
+import (
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUpdate(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	res, err := c.Update("key", func(old any) (any, error) {
+		if old != nil {
+			t.Errorf("old = %v, want nil for an absent key", old)
+		}
+		return "first", nil
+	})
+	if err != nil || res != "first" {
+		t.Fatalf("Update = %v, %v", res, err)
+	}
+
+	failure := errors.New("rejected")
+	if _, err := c.Update("key", func(any) (any, error) { return nil, failure }); !errors.Is(err, failure) {
+		t.Fatalf("Update = %v, want fn's error", err)
+	}
+	if val, err := c.Get("key"); err != nil || val != "first" {
+		t.Fatalf("Get = %v, %v, want the value kept after a failed update", val, err)
+	}
+}
+
+func TestConcurrentIncrement(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	const writers, increments = 10, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				if _, err := c.Increment("counter", 1); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if val, err := c.Get("counter"); err != nil || val != int64(writers*increments) {
+		t.Fatalf("counter = %v, %v, want %d", val, err, writers*increments)
+	}
+}
+
+func TestIncrementRejectsNonNumericValues(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	c.Set("key", "text")
+	if _, err := c.Increment("key", 1); !errors.Is(err, cache.ErrNotNumeric) {
+		t.Fatalf("Increment = %v, want ErrNotNumeric", err)
+	}
+	c.Set("key", "41")
+	if val, err := c.Increment("key", 1); err != nil || val != 42 {
+		t.Fatalf("Increment of a numeric string = %v, %v, want 42", val, err)
+	}
+}
+
+func TestGetOrSetCallsFnOnce(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := c.GetOrSet("key", func() (any, error) {
+				calls.Add(1)
+				<-release
+				return "loaded", nil
+			})
+			if err != nil || val != "loaded" {
+				t.Errorf("GetOrSet = %v, %v", val, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("fn called %d times, want once", calls.Load())
+	}
+}
@@ -0,0 +1,71 @@
+package inmem_cache
+
+import (
+	"errors"
+	"time"
+)
+
+// GetWithVersion returns the value and the version to pass to CompareAndSwap,
+// it never calls a loader
+func (c *Cache) GetWithVersion(key string) (res interface{}, version uint64, err error) {
+	defer func() {
+		if err != nil {
+			err = cacheError(GET, key, err)
+		}
+	}()
+	val, err := c.cacheAdaptor.Get(key)
+	if err != nil {
+		c.stats.Miss()
+		c.events.Emit(CacheEvent{Type: EventMiss, Key: key})
+		return nil, 0, err
+	}
+	if val.isInValidEntry(c.clock.Now(), 0) {
+		c.stats.Stale()
+		return nil, 0, ErrStaleResponse
+	}
+	c.stats.Hit()
+	c.events.Emit(CacheEvent{Type: EventHit, Key: key, Value: val.Value})
+	return val.Value, val.Version, nil
+}
+
+// CompareAndSwap stores newValue only if the entry is still at expectedVersion,
+// expectedVersion 0 stores it only if the key is absent or stale
+func (c *Cache) CompareAndSwap(key string, expectedVersion uint64, newValue any) error {
+	return c.CompareAndSwapWithTTL(key, expectedVersion, newValue, c.ttl)
+}
+
+func (c *Cache) CompareAndSwapWithTTL(key string, expectedVersion uint64, newValue any, ttl time.Duration) (err error) {
+	defer func() {
+		if err != nil {
+			err = cacheError(COMPAREANDSWAP, key, err)
+			if !errors.Is(err, ErrVersionConflict) && !errors.Is(err, ErrEntryNotFound) {
+				c.logError(COMPAREANDSWAP, key, err)
+			}
+		}
+	}()
+	err = c.swap(key, expectedVersion, c.newEntry(newValue, ttl))
+	if err == nil {
+		c.recordSet(key, newValue)
+	}
+	return err
+}
+
+func (c *Cache) swap(key string, expectedVersion uint64, cacheEntry *CacheEntry) error {
+	casAdaptor, ok := c.cacheAdaptor.(CompareAndSwapContract)
+	if !ok {
+		return ErrCompareAndSwapUnsupported
+	}
+	if expectedVersion == 0 {
+		// a stale entry counts as absent, swap against its version so a concurrent write still conflicts
+		current, err := c.cacheAdaptor.Get(key)
+		if err == nil && current.isInValidEntry(c.clock.Now(), 0) {
+			expectedVersion = current.Version
+		}
+	}
+	return casAdaptor.CompareAndSwap(key, expectedVersion, cacheEntry)
+}
+
+func (c *Cache) recordSet(key string, value any) {
+	c.stats.EntriesCount()
+	c.events.Emit(CacheEvent{Type: EventSet, Key: key, Value: value})
+}
@@ -0,0 +1,93 @@
+package inmem_cache_test
+
+import (
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompareAndSwapChecksTheVersion(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	c.Set("key", "first")
+	_, version, err := c.GetWithVersion("key")
+	if err != nil || version == 0 {
+		t.Fatalf("GetWithVersion = %d, %v, want a non zero version", version, err)
+	}
+
+	if err := c.CompareAndSwap("key", version, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CompareAndSwap("key", version, "third"); !errors.Is(err, cache.ErrVersionConflict) {
+		t.Fatalf("CompareAndSwap with a used version = %v, want ErrVersionConflict", err)
+	}
+	val, newVersion, err := c.GetWithVersion("key")
+	if err != nil || val != "second" || newVersion <= version {
+		t.Fatalf("GetWithVersion = %v, %d, %v, want second at a newer version than %d", val, newVersion, err, version)
+	}
+	if err := c.CompareAndSwap("missing", version, "value"); !errors.Is(err, cache.ErrEntryNotFound) {
+		t.Fatalf("CompareAndSwap on a missing key = %v, want ErrEntryNotFound", err)
+	}
+}
+
+func TestCompareAndSwapZeroVersionAdds(t *testing.T) {
+	c, clk := newTestCache(t, time.Minute)
+	if err := c.CompareAndSwap("key", 0, "added"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CompareAndSwap("key", 0, "again"); !errors.Is(err, cache.ErrVersionConflict) {
+		t.Fatalf("CompareAndSwap 0 on a live key = %v, want ErrVersionConflict", err)
+	}
+
+	clk.Advance(2 * time.Minute)
+	if err := c.CompareAndSwap("key", 0, "replaced"); err != nil {
+		t.Fatalf("CompareAndSwap 0 on a stale key = %v, want it treated as absent", err)
+	}
+	if val, err := c.Get("key"); err != nil || val != "replaced" {
+		t.Fatalf("Get = %v, %v, want replaced", val, err)
+	}
+}
+
+func TestCompareAndSwapWithTTL(t *testing.T) {
+	c, clk := newTestCache(t, time.Hour)
+	if err := c.CompareAndSwapWithTTL("key", 0, "value", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(2 * time.Second)
+	if _, _, err := c.GetWithVersion("key"); !errors.Is(err, cache.ErrStaleResponse) {
+		t.Fatalf("GetWithVersion after the ttl = %v, want ErrStaleResponse", err)
+	}
+}
+
+func TestConcurrentCompareAndSwapLosesNoUpdate(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	c.Set("counter", 0)
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				val, version, err := c.GetWithVersion("counter")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				err = c.CompareAndSwap("counter", version, val.(int)+1)
+				if err == nil {
+					return
+				}
+				if !errors.Is(err, cache.ErrVersionConflict) {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if val, err := c.Get("counter"); err != nil || val != writers {
+		t.Fatalf("counter = %v, %v, want %d", val, err, writers)
+	}
+}
@@ -0,0 +1,329 @@
+package inmem_cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultWarmConcurrency = 8
+
+type WarmOptions func(w *warmOptionsConfig)
+
+type warmOptionsConfig struct {
+	concurrency int
+	ttl         time.Duration
+	progress    func(progress WarmProgress)
+}
+
+// WarmProgress counts the keys handled so far, Skipped keys were already live or written concurrently
+type WarmProgress struct {
+	Total   int
+	Loaded  int
+	Skipped int
+	Failed  int
+}
+
+func (w WarmProgress) Done() int {
+	return w.Loaded + w.Skipped + w.Failed
+}
+
+func WarmWithConcurrency(concurrency int) WarmOptions {
+	return func(w *warmOptionsConfig) {
+		w.concurrency = concurrency
+	}
+}
+
+// WarmWithTTL overrides the cache wide ttl for the warmed entries
+func WarmWithTTL(ttl time.Duration) WarmOptions {
+	return func(w *warmOptionsConfig) {
+		w.ttl = ttl
+	}
+}
+
+// WarmWithProgress calls progress after every key, calls are serialized
+func WarmWithProgress(progress func(progress WarmProgress)) WarmOptions {
+	return func(w *warmOptionsConfig) {
+		w.progress = progress
+	}
+}
+
+func (c *Cache) getWarmOptions(options []WarmOptions) *warmOptionsConfig {
+	config := &warmOptionsConfig{concurrency: defaultWarmConcurrency, ttl: c.ttl}
+	for _, option := range options {
+		option(config)
+	}
+	if config.concurrency <= 0 {
+		config.concurrency = defaultWarmConcurrency
+	}
+	return config
+}
+
+// readiness is open while at least one warmup runs, a cache that was never warmed is ready
+type readiness struct {
+	mu      sync.Mutex
+	running int
+	ready   chan struct{}
+}
+
+func (r *readiness) begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running == 0 {
+		r.ready = make(chan struct{})
+	}
+	r.running++
+}
+
+func (r *readiness) end() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running--
+	if r.running == 0 {
+		close(r.ready)
+	}
+}
+
+func (r *readiness) channel() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ready == nil {
+		r.ready = make(chan struct{})
+		close(r.ready)
+	}
+	return r.ready
+}
+
+// Ready is closed once every running warmup has finished
+func (c *Cache) Ready() <-chan struct{} {
+	return c.readiness.channel()
+}
+
+// WaitReady blocks until the cache is ready or ctx is done, use a ctx with a timeout to bound startup
+func (c *Cache) WaitReady(ctx context.Context) error {
+	select {
+	case <-c.Ready():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Warm loads keys that aren't live yet through loader, at most concurrency at a time. It never
+// overwrites a value written while it runs. Failed keys don't stop the warmup, they are counted
+// and reported as ErrWarmupIncomplete.
+func (c *Cache) Warm(ctx context.Context, keys []string, loader loaderContract, options ...WarmOptions) (WarmProgress, error) {
+	c.readiness.begin()
+	defer c.readiness.end()
+	return c.warmKeys(ctx, keys, loader, options)
+}
+
+// WarmAsync runs Warm in the background and passes its outcome to done, which may be nil.
+// The cache is not ready from the moment WarmAsync returns, so a later WaitReady can't pass early.
+func (c *Cache) WarmAsync(ctx context.Context, keys []string, loader loaderContract, done func(WarmProgress, error), options ...WarmOptions) {
+	c.readiness.begin()
+	go func() {
+		defer c.readiness.end()
+		progress, err := c.warmKeys(ctx, keys, loader, options)
+		if done != nil {
+			done(progress, err)
+		}
+	}()
+}
+
+func (c *Cache) warmKeys(ctx context.Context, keys []string, loader loaderContract, options []WarmOptions) (WarmProgress, error) {
+	if loader == nil {
+		return WarmProgress{Total: len(keys)}, ErrLoaderNil
+	}
+	ttl := c.getWarmOptions(options).ttl
+	return c.warm(ctx, len(keys), options, func(ctx context.Context, group *errgroup.Group, report func(stored bool, err error)) {
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				return
+			}
+			group.Go(func() error {
+				report(c.warmKey(key, loader, ttl))
+				return nil
+			})
+		}
+	})
+}
+
+// WarmFromSnapshot stores the live entries of a snapshot written by WriteSnapshot with their
+// remaining lifetime, entries that expired in the meantime are skipped
+func (c *Cache) WarmFromSnapshot(ctx context.Context, r io.Reader, options ...WarmOptions) (WarmProgress, error) {
+	c.readiness.begin()
+	defer c.readiness.end()
+	return c.warmFromSnapshot(ctx, r, options)
+}
+
+// WarmFromSnapshotAsync is the WarmFromSnapshot counterpart of WarmAsync, r is read in the background
+func (c *Cache) WarmFromSnapshotAsync(ctx context.Context, r io.Reader, done func(WarmProgress, error), options ...WarmOptions) {
+	c.readiness.begin()
+	go func() {
+		defer c.readiness.end()
+		progress, err := c.warmFromSnapshot(ctx, r, options)
+		if done != nil {
+			done(progress, err)
+		}
+	}()
+}
+
+func (c *Cache) warmFromSnapshot(ctx context.Context, r io.Reader, options []WarmOptions) (WarmProgress, error) {
+	var entries []snapshotEntry
+	decoder := json.NewDecoder(r)
+	for {
+		var entry snapshotEntry
+		if err := decoder.Decode(&entry); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return WarmProgress{}, errors.Join(ErrInvalidSnapshot, err)
+		}
+		entries = append(entries, entry)
+	}
+	return c.warm(ctx, len(entries), options, func(ctx context.Context, group *errgroup.Group, report func(stored bool, err error)) {
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			group.Go(func() error {
+				ttl := entry.ExpiresAt.Sub(c.clock.Now())
+				if ttl <= 0 {
+					report(false, nil)
+					return nil
+				}
+				report(c.storeIfAbsent(entry.Key, entry.Value, ttl))
+				return nil
+			})
+		}
+	})
+}
+
+// warm runs the scheduled keys, the caller holds the readiness open around it
+func (c *Cache) warm(ctx context.Context, total int, options []WarmOptions, schedule func(ctx context.Context, group *errgroup.Group, report func(stored bool, err error))) (WarmProgress, error) {
+	config := c.getWarmOptions(options)
+	progress := WarmProgress{Total: total}
+	var mu sync.Mutex
+	report := func(stored bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			progress.Failed++
+		case stored:
+			progress.Loaded++
+		default:
+			progress.Skipped++
+		}
+		if config.progress != nil {
+			config.progress(progress)
+		}
+	}
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(config.concurrency)
+	schedule(groupCtx, group, report)
+	group.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return progress, err
+	}
+	if progress.Failed > 0 {
+		return progress, ErrWarmupIncomplete
+	}
+	return progress, nil
+}
+
+func (c *Cache) warmKey(key string, loader loaderContract, ttl time.Duration) (bool, error) {
+	if _, _, found := c.read(key); found {
+		return false, nil
+	}
+	val, err := c.load(key, loader)
+	if err != nil {
+		return false, errors.Join(ErrLoaderFailed, err)
+	}
+	return c.storeIfAbsent(key, val, ttl)
+}
+
+// storeIfAbsent writes val unless the key holds a live entry, losing a race to a writer is not an error
+func (c *Cache) storeIfAbsent(key string, val any, ttl time.Duration) (bool, error) {
+	cacheEntry := c.newEntry(val, ttl)
+	err := c.swap(key, 0, cacheEntry)
+	if errors.Is(err, ErrCompareAndSwapUnsupported) {
+		unlock := c.keyLocks.lock(key)
+		defer unlock()
+		if _, _, found := c.read(key); found {
+			return false, nil
+		}
+		err = c.cacheAdaptor.Set(key, cacheEntry)
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	c.recordSet(key, val)
+	return true, nil
+}
+
+type snapshotEntry struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// WriteSnapshot writes every live entry as a json line, the adaptor has to implement KeysContract
+func (c *Cache) WriteSnapshot(w io.Writer) error {
+	keysAdaptor, ok := c.cacheAdaptor.(KeysContract)
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+	keys, err := keysAdaptor.Keys()
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	now := c.clock.Now()
+	for _, key := range keys {
+		if isProbeKey(key) {
+			continue
+		}
+		val, err := c.cacheAdaptor.Get(key)
+		if err != nil || val.isInValidEntry(now, 0) {
+			continue
+		}
+		if err := encoder.Encode(snapshotEntry{Key: key, Value: val.Value, ExpiresAt: val.ExpiresAt}); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// KeysFromFile reads one key per line, blank lines and lines starting with # are ignored
+func KeysFromFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
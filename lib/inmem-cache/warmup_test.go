@@ -0,0 +1,98 @@
+package inmem_cache_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"testing"
+	"time"
+)
+
+func TestWarmAsyncIsNotReadyBeforeItReturns(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	release := make(chan struct{})
+	done := make(chan cache.WarmProgress, 1)
+	c.WarmAsync(context.Background(), []string{"a", "b"}, func(key string) (interface{}, error) {
+		<-release
+		return key, nil
+	}, func(progress cache.WarmProgress, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		done <- progress
+	})
+
+	select {
+	case <-c.Ready():
+		t.Fatal("ready while the warmup is still loading")
+	default:
+	}
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if progress := <-done; progress.Loaded != 2 {
+		t.Fatalf("progress = %+v, want both keys loaded", progress)
+	}
+}
+
+func TestWarmSkipsLiveKeysAndCountsFailures(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	c.Set("live", "kept")
+	progress, err := c.Warm(context.Background(), []string{"live", "new", "broken"}, func(key string) (interface{}, error) {
+		if key == "broken" {
+			return nil, errors.New("backend down")
+		}
+		return "loaded", nil
+	})
+	if !errors.Is(err, cache.ErrWarmupIncomplete) {
+		t.Fatalf("Warm = %v, want ErrWarmupIncomplete", err)
+	}
+	if progress != (cache.WarmProgress{Total: 3, Loaded: 1, Skipped: 1, Failed: 1}) {
+		t.Fatalf("progress = %+v", progress)
+	}
+	if val, _ := c.Get("live"); val != "kept" {
+		t.Fatalf("live key = %v, want it untouched", val)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	source, clk := newTestCache(t, time.Minute)
+	source.Set("short", "gone")
+	clk.Advance(30 * time.Second)
+	source.Set("long", "kept")
+	var snapshot bytes.Buffer
+	if err := source.WriteSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	target, targetClock := newTestCache(t, time.Minute)
+	targetClock.Set(clk.Now().Add(45 * time.Second))
+	progress, err := target.WarmFromSnapshot(context.Background(), &snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Loaded != 1 || progress.Skipped != 1 {
+		t.Fatalf("progress = %+v, want the expired entry skipped", progress)
+	}
+	if ttl, err := target.TTL("long"); err != nil || ttl != 15*time.Second {
+		t.Fatalf("TTL = %v, %v, want the remaining 15s", ttl, err)
+	}
+}
+
+// keylessAdaptor hides the KeysContract of the map adaptor
+type keylessAdaptor struct {
+	cache.CacheAdaptorServiceContract
+}
+
+func TestWriteSnapshotNeedsKeys(t *testing.T) {
+	c := cache.GetCache(keylessAdaptor{map_cache.CreateMapCache()}, time.Minute, true)
+	t.Cleanup(c.Close)
+	if err := c.WriteSnapshot(&bytes.Buffer{}); !errors.Is(err, cache.ErrSnapshotUnsupported) {
+		t.Fatalf("WriteSnapshot = %v, want ErrSnapshotUnsupported", err)
+	}
+}
@@ -0,0 +1,44 @@
+package loadgen
+
+import (
+	"slices"
+	"time"
+)
+
+type Latency struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	P999  time.Duration `json:"p999"`
+	Max   time.Duration `json:"max"`
+}
+
+// samples is owned by a single worker, they are merged once the run is over
+type samples []time.Duration
+
+func summarize(all []samples) Latency {
+	total := 0
+	for _, s := range all {
+		total += len(s)
+	}
+	if total == 0 {
+		return Latency{}
+	}
+	merged := make([]time.Duration, 0, total)
+	for _, s := range all {
+		merged = append(merged, s...)
+	}
+	slices.Sort(merged)
+	percentile := func(p float64) time.Duration {
+		return merged[int(p*float64(len(merged)-1))]
+	}
+	return Latency{
+		Count: total,
+		P50:   percentile(0.50),
+		P90:   percentile(0.90),
+		P99:   percentile(0.99),
+		P999:  percentile(0.999),
+		Max:   merged[len(merged)-1],
+	}
+}
@@ -0,0 +1,216 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cache "inmem/lib/inmem-cache"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errMockLoader = errors.New("mock loader failure")
+
+type opType int
+
+const (
+	opRead opType = iota
+	opWrite
+	opDelete
+	opCount
+)
+
+func (o opType) String() string {
+	switch o {
+	case opRead:
+		return "read"
+	case opWrite:
+		return "write"
+	default:
+		return "delete"
+	}
+}
+
+type Report struct {
+	Elapsed    time.Duration      `json:"elapsed"`
+	Ops        int                `json:"ops"`
+	Throughput float64            `json:"throughput"`
+	Errors     map[string]int     `json:"errors"`
+	Latency    map[string]Latency `json:"latency"`
+	// Hits, Misses and HitRatio are the CacheStats delta over the run, zero when stats are off
+	Hits     int32   `json:"hits"`
+	Misses   int32   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+	Loads    int32   `json:"loads"`
+	Stale    int32   `json:"stale_served"`
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ops=%d elapsed=%s throughput=%.1f/s hits=%d misses=%d hit_ratio=%.2f%% loads=%d stale=%d\n",
+		r.Ops, r.Elapsed.Round(time.Millisecond), r.Throughput, r.Hits, r.Misses, r.HitRatio, r.Loads, r.Stale)
+	for op := opRead; op < opCount; op++ {
+		latency := r.Latency[op.String()]
+		fmt.Fprintf(&b, "%-6s n=%d errors=%d p50=%s p90=%s p99=%s p999=%s max=%s\n",
+			op, latency.Count, r.Errors[op.String()], latency.P50, latency.P90, latency.P99, latency.P999, latency.Max)
+	}
+	return b.String()
+}
+
+type workerResult struct {
+	latencies [opCount]samples
+	errors    [opCount]int
+}
+
+// Run replays the workload against c until the duration is over or ctx is done.
+// Reads go through a mock loader so misses cost loaderLatency, like a real backend would.
+func Run(ctx context.Context, c *cache.Cache, optionalWorkloadConfigs ...OptionalWorkloadConfig) (*Report, error) {
+	if c == nil {
+		return nil, ErrCacheNil
+	}
+	workload := defaultWorkload()
+	for _, option := range optionalWorkloadConfigs {
+		option(workload)
+	}
+	if err := workload.validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, workload.duration)
+	defer cancel()
+
+	before := c.GetStats().Snapshot()
+	jobs := make(chan struct{}, workload.workers)
+	results := make([]*workerResult, workload.workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < workload.workers; i++ {
+		results[i] = &workerResult{}
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			workload.work(c, jobs, results[worker], workload.seed+uint64(worker))
+		}(i)
+	}
+	workload.pace(ctx, jobs)
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+	after := c.GetStats().Snapshot()
+
+	report := &Report{
+		Elapsed: elapsed,
+		Errors:  make(map[string]int),
+		Latency: make(map[string]Latency),
+		Hits:    after.Hits - before.Hits,
+		Misses:  after.Misses - before.Misses,
+		Loads:   after.LoadCount - before.LoadCount,
+		Stale:   after.StaleServed - before.StaleServed,
+	}
+	for op := opRead; op < opCount; op++ {
+		perWorker := make([]samples, 0, len(results))
+		for _, result := range results {
+			perWorker = append(perWorker, result.latencies[op])
+			report.Errors[op.String()] += result.errors[op]
+		}
+		latency := summarize(perWorker)
+		report.Latency[op.String()] = latency
+		report.Ops += latency.Count
+	}
+	report.Throughput = float64(report.Ops) / elapsed.Seconds()
+	if lookups := report.Hits + report.Misses; lookups > 0 {
+		report.HitRatio = float64(report.Hits) / float64(lookups) * 100
+	}
+	return report, nil
+}
+
+// pace hands out one job per operation, spread over millisecond slots to hold the target qps
+func (w *Workload) pace(ctx context.Context, jobs chan<- struct{}) {
+	if w.qps <= 0 {
+		for {
+			select {
+			case jobs <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	const slot = time.Millisecond
+	ticker := time.NewTicker(slot)
+	defer ticker.Stop()
+	start := time.Now()
+	sent := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			due := int(now.Sub(start).Seconds() * float64(w.qps))
+			for ; sent < due; sent++ {
+				select {
+				case jobs <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *Workload) work(c *cache.Cache, jobs <-chan struct{}, result *workerResult, seed uint64) {
+	random := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	var zipf *rand.Zipf
+	if w.zipfS > 1 {
+		zipf = rand.NewZipf(random, w.zipfS, 1, uint64(w.keys-1))
+	}
+	value := strings.Repeat("x", w.valueSize)
+	loader := func(key string) (interface{}, error) {
+		time.Sleep(w.loaderLatency)
+		if w.loaderErrorRate > 0 && random.Float64() < w.loaderErrorRate {
+			return nil, errMockLoader
+		}
+		return value, nil
+	}
+	total := w.readRatio + w.writeRatio + w.deleteRatio
+	for range jobs {
+		var keyIndex uint64
+		if zipf != nil {
+			keyIndex = zipf.Uint64()
+		} else {
+			keyIndex = random.Uint64N(uint64(w.keys))
+		}
+		key := "loadgen:" + strconv.FormatUint(keyIndex, 10)
+
+		op := opDelete
+		if pick := random.Float64() * total; pick < w.readRatio {
+			op = opRead
+		} else if pick < w.readRatio+w.writeRatio {
+			op = opWrite
+		}
+
+		var err error
+		start := time.Now()
+		switch op {
+		case opRead:
+			if w.staleWindow > 0 {
+				_, err = c.Get(key, cache.WithStaleResponse(w.staleWindow, cache.WithLoader(loader)))
+			} else {
+				_, err = c.Get(key, cache.WithLoader(loader))
+			}
+		case opWrite:
+			err = c.Set(key, value)
+		case opDelete:
+			_, err = c.Delete(cache.DeleteWithKeys([]string{key}))
+			// deleting an absent key is part of the workload, not a failure
+			if errors.Is(err, cache.ErrEntryNotFound) {
+				err = nil
+			}
+		}
+		result.latencies[op] = append(result.latencies[op], time.Since(start))
+		if err != nil {
+			result.errors[op]++
+		}
+	}
+}
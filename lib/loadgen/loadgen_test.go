@@ -0,0 +1,83 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	cache "inmem/lib/inmem-cache"
+	map_cache "inmem/lib/inmem-cache/map-cache"
+	"inmem/lib/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T) *cache.Cache {
+	t.Helper()
+	c := cache.GetCache(map_cache.CreateMapCache(), time.Minute, true)
+	t.Cleanup(c.Close)
+	return c
+}
+
+// captureLogs sends every entry to a file until the test ends and returns its path
+func captureLogs(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.log")
+	err := logger.Configure(logger.Config{
+		Level: string(logger.DEBUG),
+		Sinks: []logger.SinkConfig{{Type: "file", Path: path, Format: "json"}},
+		Async: logger.AsyncConfig{Disabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logger.Configure(logger.DefaultConfig()) })
+	return path
+}
+
+func TestRunReportsEveryOperation(t *testing.T) {
+	report, err := Run(context.Background(), newTestCache(t),
+		WithQPS(2000), WithDuration(200*time.Millisecond), WithWorkers(4), WithKeys(50),
+		WithLoaderLatency(0), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Ops == 0 || report.Hits+report.Misses == 0 {
+		t.Fatalf("report = %+v, want reads and writes", report)
+	}
+	for op, count := range report.Errors {
+		if count != 0 {
+			t.Fatalf("%d %s errors without a failing loader", count, op)
+		}
+	}
+}
+
+func TestDeletingAbsentKeysIsNotAnError(t *testing.T) {
+	logs := captureLogs(t)
+	report, err := Run(context.Background(), newTestCache(t),
+		WithRatios(0, 0, 1), WithQPS(500), WithDuration(100*time.Millisecond), WithWorkers(2), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Latency["delete"].Count == 0 || report.Errors["delete"] != 0 {
+		t.Fatalf("delete count %d with %d errors, want deletes of absent keys without errors",
+			report.Latency["delete"].Count, report.Errors["delete"])
+	}
+	content, err := os.ReadFile(logs)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "cache delete failed") {
+		t.Fatalf("absent key deletes were logged as errors:\n%s", content)
+	}
+}
+
+func TestInvalidWorkload(t *testing.T) {
+	if _, err := Run(context.Background(), newTestCache(t), WithRatios(0, 0, 0)); !errors.Is(err, ErrInvalidRatios) {
+		t.Fatalf("Run = %v, want ErrInvalidRatios", err)
+	}
+	if _, err := Run(context.Background(), nil); !errors.Is(err, ErrCacheNil) {
+		t.Fatalf("Run = %v, want ErrCacheNil", err)
+	}
+}
@@ -0,0 +1,136 @@
+package loadgen
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidRatios = errors.New("read, write and delete ratios must be >= 0 and add up to more than 0")
+	ErrInvalidZipf   = errors.New("zipf exponent must be 0 (uniform) or greater than 1")
+	ErrCacheNil      = errors.New("cache is nil")
+)
+
+// Workload describes the traffic Run replays, build it through the With* options
+type Workload struct {
+	keys            int
+	readRatio       float64
+	writeRatio      float64
+	deleteRatio     float64
+	zipfS           float64
+	qps             int
+	duration        time.Duration
+	workers         int
+	loaderLatency   time.Duration
+	loaderErrorRate float64
+	valueSize       int
+	staleWindow     time.Duration
+	seed            uint64
+}
+
+type OptionalWorkloadConfig func(w *Workload)
+
+func defaultWorkload() *Workload {
+	return &Workload{
+		keys:          1000,
+		readRatio:     0.8,
+		writeRatio:    0.15,
+		deleteRatio:   0.05,
+		zipfS:         1.1,
+		qps:           1000,
+		duration:      10 * time.Second,
+		workers:       32,
+		loaderLatency: 5 * time.Millisecond,
+		valueSize:     256,
+		seed:          1,
+	}
+}
+
+func WithKeys(keys int) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.keys = keys
+	}
+}
+
+// WithRatios sets the share of reads, writes and deletes, they are normalized so 8,1,1 works too
+func WithRatios(read float64, write float64, delete float64) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.readRatio = read
+		w.writeRatio = write
+		w.deleteRatio = delete
+	}
+}
+
+// WithZipf picks keys from a zipfian distribution with exponent s, 0 picks them uniformly
+func WithZipf(s float64) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.zipfS = s
+	}
+}
+
+// WithQPS sets the target rate, 0 runs as fast as the workers can go
+func WithQPS(qps int) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.qps = qps
+	}
+}
+
+func WithDuration(duration time.Duration) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.duration = duration
+	}
+}
+
+func WithWorkers(workers int) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.workers = workers
+	}
+}
+
+// WithLoaderLatency sets how long the mock loader takes for every miss
+func WithLoaderLatency(latency time.Duration) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.loaderLatency = latency
+	}
+}
+
+func WithLoaderErrorRate(rate float64) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.loaderErrorRate = rate
+	}
+}
+
+func WithValueSize(size int) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.valueSize = size
+	}
+}
+
+// WithStaleWindow reads with cache.WithStaleResponse so expired entries inside the window are reloaded
+func WithStaleWindow(window time.Duration) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.staleWindow = window
+	}
+}
+
+func WithSeed(seed uint64) OptionalWorkloadConfig {
+	return func(w *Workload) {
+		w.seed = seed
+	}
+}
+
+func (w *Workload) validate() error {
+	if w.readRatio < 0 || w.writeRatio < 0 || w.deleteRatio < 0 || w.readRatio+w.writeRatio+w.deleteRatio <= 0 {
+		return ErrInvalidRatios
+	}
+	if w.zipfS != 0 && w.zipfS <= 1 {
+		return ErrInvalidZipf
+	}
+	if w.keys <= 0 {
+		w.keys = 1
+	}
+	if w.workers <= 0 {
+		w.workers = 1
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type OverflowPolicy string
+
+const (
+	// OverflowBlock makes Dispatch wait for room, nothing is lost
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest discards the entry being dispatched
+	OverflowDropNewest OverflowPolicy = "drop-newest"
+	// OverflowDropOldest discards the oldest queued entry to make room
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	// OverflowSample keeps one in sampleRate overflowing entries, in place of the oldest one
+	OverflowSample OverflowPolicy = "sample"
+)
+
+const (
+	defaultAsyncBufferSize = 4096
+	defaultAsyncWorkers    = 1
+	defaultAsyncBatchSize  = 64
+	defaultAsyncSampleRate = 10
+)
+
+// BatchDispatcher is implemented by dispatchers that can write several entries at once
+type BatchDispatcher interface {
+	DispatchBatch(entries []*LogEntry)
+}
+
+// AsyncDispatcher queues entries in a bounded ring buffer and writes them to next from its own
+// workers, in batches of up to batchSize. After Close it dispatches synchronously so late
+// entries, like the ones logged by the last shutdown hooks, still reach next.
+type AsyncDispatcher struct {
+	next       LogDispatcher
+	policy     OverflowPolicy
+	workers    int
+	batchSize  int
+	sampleRate int64
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+	ring     []*LogEntry
+	head     int
+	size     int
+	inFlight int
+	closed   bool
+	wg       sync.WaitGroup
+
+	overflowed atomic.Int64
+	dropped    atomic.Int64
+}
+
+type OptionalAsyncConfig func(a *AsyncDispatcher)
+
+func WithBufferSize(size int) OptionalAsyncConfig {
+	return func(a *AsyncDispatcher) {
+		if size > 0 {
+			a.ring = make([]*LogEntry, size)
+		}
+	}
+}
+
+func WithWorkers(workers int) OptionalAsyncConfig {
+	return func(a *AsyncDispatcher) {
+		if workers > 0 {
+			a.workers = workers
+		}
+	}
+}
+
+func WithBatchSize(batchSize int) OptionalAsyncConfig {
+	return func(a *AsyncDispatcher) {
+		if batchSize > 0 {
+			a.batchSize = batchSize
+		}
+	}
+}
+
+// WithOverflowPolicy picks what Dispatch does when the buffer is full, sampleRate is only used by OverflowSample
+func WithOverflowPolicy(policy OverflowPolicy, sampleRate int) OptionalAsyncConfig {
+	return func(a *AsyncDispatcher) {
+		a.policy = policy
+		if sampleRate > 0 {
+			a.sampleRate = int64(sampleRate)
+		}
+	}
+}
+
+func NewAsyncDispatcher(next LogDispatcher, optionalAsyncConfigs ...OptionalAsyncConfig) *AsyncDispatcher {
+	a := &AsyncDispatcher{
+		next:       next,
+		policy:     OverflowBlock,
+		workers:    defaultAsyncWorkers,
+		batchSize:  defaultAsyncBatchSize,
+		sampleRate: defaultAsyncSampleRate,
+		ring:       make([]*LogEntry, defaultAsyncBufferSize),
+	}
+	for _, option := range optionalAsyncConfigs {
+		option(a)
+	}
+	a.notEmpty = sync.NewCond(&a.mu)
+	a.notFull = sync.NewCond(&a.mu)
+	a.idle = sync.NewCond(&a.mu)
+	for i := 0; i < a.workers; i++ {
+		a.wg.Add(1)
+		go a.run()
+	}
+	return a
+}
+
+func (a *AsyncDispatcher) Dispatch(l *LogEntry) {
+	a.mu.Lock()
+	if a.size == len(a.ring) && !a.closed && !a.overflow() {
+		a.mu.Unlock()
+		return
+	}
+	if a.closed {
+		a.mu.Unlock()
+		a.next.Dispatch(l)
+		return
+	}
+	a.ring[(a.head+a.size)%len(a.ring)] = l
+	a.size++
+	a.mu.Unlock()
+	a.notEmpty.Signal()
+}
+
+// overflow makes room for one entry according to the policy, false means the entry is dropped.
+// It is called with mu held and can return with the dispatcher closed when the policy blocks.
+func (a *AsyncDispatcher) overflow() bool {
+	a.overflowed.Add(1)
+	switch a.policy {
+	case OverflowDropNewest:
+		a.dropped.Add(1)
+		return false
+	case OverflowSample:
+		if a.overflowed.Load()%a.sampleRate != 0 {
+			a.dropped.Add(1)
+			return false
+		}
+		a.dropOldest()
+		return true
+	case OverflowDropOldest:
+		a.dropOldest()
+		return true
+	default:
+		for a.size == len(a.ring) && !a.closed {
+			a.notFull.Wait()
+		}
+		return true
+	}
+}
+
+func (a *AsyncDispatcher) dropOldest() {
+	a.ring[a.head] = nil
+	a.head = (a.head + 1) % len(a.ring)
+	a.size--
+	a.dropped.Add(1)
+}
+
+func (a *AsyncDispatcher) run() {
+	defer a.wg.Done()
+	batch := make([]*LogEntry, 0, a.batchSize)
+	for {
+		a.mu.Lock()
+		for a.size == 0 && !a.closed {
+			a.notEmpty.Wait()
+		}
+		if a.size == 0 && a.closed {
+			a.mu.Unlock()
+			return
+		}
+		for a.size > 0 && len(batch) < a.batchSize {
+			batch = append(batch, a.ring[a.head])
+			a.ring[a.head] = nil
+			a.head = (a.head + 1) % len(a.ring)
+			a.size--
+		}
+		a.inFlight++
+		a.mu.Unlock()
+		a.notFull.Broadcast()
+
+		dispatchBatch(a.next, batch)
+
+		a.mu.Lock()
+		a.inFlight--
+		if a.size == 0 && a.inFlight == 0 {
+			a.idle.Broadcast()
+		}
+		a.mu.Unlock()
+		clear(batch)
+		batch = batch[:0]
+	}
+}
+
+// Flush waits until every queued entry has been written or ctx is done
+func (a *AsyncDispatcher) Flush(ctx context.Context) error {
+	// sync.Cond can't wait on ctx, wake the wait below once ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.idle.Broadcast()
+	})
+	defer stop()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for (a.size > 0 || a.inFlight > 0) && !a.closed {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		a.idle.Wait()
+	}
+	return nil
+}
+
+// Close stops accepting entries, drains the queue and stops the workers. Entries dispatched
+// afterwards are written synchronously. A Close that timed out can be called again to keep
+// waiting for the drain.
+func (a *AsyncDispatcher) Close(ctx context.Context) error {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	a.notEmpty.Broadcast()
+	a.notFull.Broadcast()
+	a.idle.Broadcast()
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dropped is the number of entries discarded by the overflow policy
+func (a *AsyncDispatcher) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// Overflowed is the number of Dispatch calls that found the buffer full, whatever the policy did with them
+func (a *AsyncDispatcher) Overflowed() int64 {
+	return a.overflowed.Load()
+}
+
+// Len is the number of entries waiting to be written
+func (a *AsyncDispatcher) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.size
+}
+
+func (a *AsyncDispatcher) writingSince() time.Time {
+	if tracker, ok := a.next.(writeTracker); ok {
+		return tracker.writingSince()
+	}
+	return time.Time{}
+}
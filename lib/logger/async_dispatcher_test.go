@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingDispatcher keeps the entries it gets, gate holds every Dispatch until it is closed
+type recordingDispatcher struct {
+	mu      sync.Mutex
+	entries []*LogEntry
+	gate    chan struct{}
+	closed  bool
+}
+
+func (r *recordingDispatcher) Dispatch(l *LogEntry) {
+	if r.gate != nil {
+		<-r.gate
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, l)
+}
+
+func (r *recordingDispatcher) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *recordingDispatcher) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := make([]string, 0, len(r.entries))
+	for _, entry := range r.entries {
+		messages = append(messages, entry.Msg)
+	}
+	return messages
+}
+
+func (r *recordingDispatcher) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+func TestAsyncDispatcherDeliversInOrder(t *testing.T) {
+	next := &recordingDispatcher{}
+	async := NewAsyncDispatcher(next, WithBatchSize(3))
+	for _, message := range []string{"a", "b", "c", "d", "e"} {
+		async.Dispatch(WithEntry().WithMessage(message))
+	}
+	if err := async.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := next.messages(); len(got) != 5 || got[0] != "a" || got[4] != "e" {
+		t.Fatalf("delivered %v, want a to e in order", got)
+	}
+	async.Close(context.Background())
+	async.Dispatch(WithEntry().WithMessage("late"))
+	if got := next.messages(); got[len(got)-1] != "late" {
+		t.Fatalf("entry after Close not written synchronously: %v", got)
+	}
+}
+
+func TestAsyncDispatcherDropNewest(t *testing.T) {
+	next := &recordingDispatcher{gate: make(chan struct{})}
+	async := NewAsyncDispatcher(next, WithBufferSize(2), WithBatchSize(1), WithOverflowPolicy(OverflowDropNewest, 0))
+	// the worker holds the first entry, two more fill the buffer
+	for i := 0; i < 6; i++ {
+		async.Dispatch(WithEntry().WithMessage("entry"))
+	}
+	close(next.gate)
+	async.Close(context.Background())
+	if dropped, delivered := async.Dropped(), len(next.messages()); dropped == 0 || int(dropped)+delivered != 6 {
+		t.Fatalf("dropped %d and delivered %d of 6", dropped, delivered)
+	}
+}
+
+func TestFlushDoesNotLeakWhenCtxExpires(t *testing.T) {
+	next := &recordingDispatcher{gate: make(chan struct{})}
+	defer close(next.gate)
+	async := NewAsyncDispatcher(next)
+	async.Dispatch(WithEntry().WithMessage("stuck"))
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if err := async.Flush(ctx); err == nil {
+			t.Fatal("Flush returned nil while the writer is stuck")
+		}
+		cancel()
+	}
+	if after := runtime.NumGoroutine(); after > before+2 {
+		t.Fatalf("goroutines went from %d to %d after timed out flushes", before, after)
+	}
+}
+
+func TestCloseDispatcherKeepsNextOpenWhenDrainTimesOut(t *testing.T) {
+	next := &recordingDispatcher{gate: make(chan struct{})}
+	async := NewAsyncDispatcher(next)
+	async.Dispatch(WithEntry().WithMessage("stuck"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := closeDispatcher(ctx, async); err == nil {
+		t.Fatal("closeDispatcher returned nil while the writer is stuck")
+	}
+	if next.isClosed() {
+		t.Fatal("next closed under a worker that is still writing")
+	}
+
+	close(next.gate)
+	if err := closeDispatcher(context.Background(), async); err != nil {
+		t.Fatal(err)
+	}
+	if !next.isClosed() {
+		t.Fatal("next not closed after the drain")
+	}
+}
+
+func TestShutdownHookClosesTheSinks(t *testing.T) {
+	next := &recordingDispatcher{}
+	previous := l.swap(NewAsyncDispatcher(next))
+	t.Cleanup(func() { l.swap(previous) })
+
+	Dispatch(INFO, "last words")
+	ShutdownHook(context.Background())
+	if got := next.messages(); len(got) != 1 || got[0] != "last words" {
+		t.Fatalf("sink got %v, want the queued entry", got)
+	}
+	if !next.isClosed() {
+		t.Fatal("sink left open after ShutdownHook")
+	}
+}
@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"context"
+	"time"
+)
+
+// Logger carries bound fields into every entry it dispatches, e.g. component=cache cache=todo.
+// Loggers are immutable, With returns a new one. The zero value logs without bound fields.
+type Logger struct {
+	fields    Fields
+	component string
+}
+
+// With returns a logger binding fields, a String field keyed component also selects the
+// per-component level of its entries
+func With(fields ...Field) *Logger {
+	return (&Logger{}).With(fields...)
+}
+
+// Named is With(String("component", component))
+func Named(component string) *Logger {
+	return With(String(componentField, component))
+}
+
+func (lg *Logger) With(fields ...Field) *Logger {
+	child := &Logger{component: lg.component}
+	child.fields = make(Fields, len(lg.fields), len(lg.fields)+len(fields))
+	copy(child.fields, lg.fields)
+	for _, field := range fields {
+		child.fields = child.fields.set(field)
+		if field.Key == componentField && field.Kind == StringKind {
+			child.component = field.str
+		}
+	}
+	return child
+}
+
+// Enabled reports whether an entry at level from this logger would be written
+func (lg *Logger) Enabled(level Level) bool {
+	return EnabledFor(lg.component, level)
+}
+
+// Log dispatches msg with the bound fields followed by fields, later fields win on equal keys
+func (lg *Logger) Log(level Level, msg string, fields ...Field) {
+	if !lg.Enabled(level) {
+		return
+	}
+	lg.dispatch(level, WithEntry().WithMessage(msg), fields)
+}
+
+// LogEntry dispatches a prepared entry, its own fields win over the bound ones
+func (lg *Logger) LogEntry(level Level, entry *LogEntry) {
+	if !lg.Enabled(level) {
+		return
+	}
+	lg.dispatch(level, entry, entry.Fields)
+}
+
+// dispatch sets the bound fields followed by fields on entry, merging into one slice sized up front
+// keeps the allocations of an entry the same however many fields it has
+func (lg *Logger) dispatch(level Level, entry *LogEntry, fields Fields) {
+	merged := make(Fields, len(lg.fields), len(lg.fields)+len(fields))
+	copy(merged, lg.fields)
+	for _, field := range fields {
+		merged = merged.set(field)
+	}
+	entry.Fields = merged
+	l.dispatch(entry.withTime(time.Now()).withLevel(level))
+}
+
+func (lg *Logger) Debug(msg string, fields ...Field) {
+	lg.Log(DEBUG, msg, fields...)
+}
+
+func (lg *Logger) Info(msg string, fields ...Field) {
+	lg.Log(INFO, msg, fields...)
+}
+
+func (lg *Logger) Warn(msg string, fields ...Field) {
+	lg.Log(WARN, msg, fields...)
+}
+
+func (lg *Logger) Error(msg string, fields ...Field) {
+	lg.Log(ERROR, msg, fields...)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying lg, e.g. a logger bound to a request id
+func NewContext(ctx context.Context, lg *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, lg)
+}
+
+// FromContext returns the logger stored by NewContext, or one without bound fields
+func FromContext(ctx context.Context) *Logger {
+	if lg, ok := ctx.Value(contextKey{}).(*Logger); ok && lg != nil {
+		return lg
+	}
+	return &Logger{}
+}
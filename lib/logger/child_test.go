@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type loggedEntry struct {
+	Fields map[string]any
+	Msg    string
+	Level  Level
+}
+
+func readEntries(t *testing.T, path string) []loggedEntry {
+	t.Helper()
+	var entries []loggedEntry
+	for _, line := range strings.Split(strings.TrimSpace(readLogs(t, path)), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry loggedEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("%v: %s", err, line)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestChildLoggersBindFields(t *testing.T) {
+	path := captureLogs(t)
+	parent := Named("cache").With(String("cache", "todo"))
+	child := parent.With(String("request", "r1"))
+	child.Info("hit", String("cache", "override"), Int("size", 3))
+	parent.Warn("miss")
+
+	entries := readEntries(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	hit := entries[0]
+	if hit.Msg != "hit" || hit.Level != INFO || hit.Fields["component"] != "cache" || hit.Fields["request"] != "r1" {
+		t.Fatalf("child entry = %+v", hit)
+	}
+	if hit.Fields["cache"] != "override" || hit.Fields["size"] != float64(3) {
+		t.Fatalf("call fields did not win over bound ones: %+v", hit.Fields)
+	}
+	if _, ok := entries[1].Fields["request"]; ok || entries[1].Fields["cache"] != "todo" {
+		t.Fatalf("child fields leaked into the parent: %+v", entries[1].Fields)
+	}
+}
+
+func TestChildLoggersFollowComponentLevels(t *testing.T) {
+	path := captureLogs(t)
+	SetLevel(INFO)
+	SetComponentLevel("peers", ERROR)
+	peers := Named("peers")
+	if peers.Enabled(WARN) || !Named("cache").Enabled(INFO) {
+		t.Fatal("Enabled ignores the component level")
+	}
+	peers.Warn("dropped")
+	peers.Error("kept")
+	With(String("other", "x")).Debug("dropped too")
+
+	entries := readEntries(t, path)
+	if len(entries) != 1 || entries[0].Msg != "kept" {
+		t.Fatalf("logged %+v, want only kept", entries)
+	}
+}
+
+func TestLoggerTravelsInTheContext(t *testing.T) {
+	path := captureLogs(t)
+	if lg := FromContext(context.Background()); lg == nil {
+		t.Fatal("FromContext without a logger returned nil")
+	}
+	ctx := NewContext(context.Background(), With(String("request", "r2")))
+	FromContext(ctx).Info("handled")
+
+	entries := readEntries(t, path)
+	if len(entries) != 1 || entries[0].Fields["request"] != "r2" {
+		t.Fatalf("logged %+v, want the request field from the context logger", entries)
+	}
+}
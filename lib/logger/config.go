@@ -0,0 +1,277 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	configFileEnv      = "LOG_CONFIG"
+	levelEnv           = "LOG_LEVEL"
+	componentLevelsEnv = "LOG_COMPONENT_LEVELS"
+	sinksEnv           = "LOG_SINKS"
+	asyncEnv           = "LOG_ASYNC"
+	asyncBufferEnv     = "LOG_ASYNC_BUFFER"
+	asyncWorkersEnv    = "LOG_ASYNC_WORKERS"
+	asyncOverflowEnv   = "LOG_ASYNC_OVERFLOW"
+	samplingEnv        = "LOG_SAMPLING"
+
+	// closeTimeout bounds how long Configure waits for the previous dispatcher to drain
+	closeTimeout = 5 * time.Second
+)
+
+var (
+	ErrUnknownOverflowPolicy = errors.New("unknown overflow policy")
+	ErrUnknownConfigFormat   = errors.New("unknown logger config file format")
+	ErrInvalidEnv            = errors.New("invalid logger environment variable")
+)
+
+// Config is the whole logger setup, empty fields take the DefaultConfig values
+type Config struct {
+	Level      string            `json:"level,omitempty" yaml:"level,omitempty"`
+	Components map[string]string `json:"components,omitempty" yaml:"components,omitempty"`
+	Sinks      []SinkConfig      `json:"sinks,omitempty" yaml:"sinks,omitempty"`
+	Async      AsyncConfig       `json:"async,omitempty" yaml:"async,omitempty"`
+}
+
+// AsyncConfig wraps the sinks in an AsyncDispatcher unless Disabled
+type AsyncConfig struct {
+	Disabled   bool   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	BufferSize int    `json:"bufferSize,omitempty" yaml:"bufferSize,omitempty"`
+	Workers    int    `json:"workers,omitempty" yaml:"workers,omitempty"`
+	BatchSize  int    `json:"batchSize,omitempty" yaml:"batchSize,omitempty"`
+	Overflow   string `json:"overflow,omitempty" yaml:"overflow,omitempty"`
+	SampleRate int    `json:"sampleRate,omitempty" yaml:"sampleRate,omitempty"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Level: string(defaultLevel),
+		Sinks: DefaultSinks(),
+		Async: AsyncConfig{Overflow: string(OverflowBlock)},
+	}
+}
+
+func ParseOverflowPolicy(policy string) (OverflowPolicy, error) {
+	switch parsed := OverflowPolicy(strings.ToLower(strings.TrimSpace(policy))); parsed {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowSample:
+		return parsed, nil
+	case "":
+		return OverflowBlock, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownOverflowPolicy, policy)
+}
+
+// LoadConfig reads a yaml (.yaml, .yml) or json (.json) config file
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &cfg)
+	case ".json":
+		err = json.Unmarshal(raw, &cfg)
+	default:
+		return cfg, fmt.Errorf("%w: %q", ErrUnknownConfigFormat, path)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ConfigFromEnv starts from the LOG_CONFIG file when set, or DefaultConfig, and applies the
+// LOG_* overrides on top:
+//
+//	LOG_LEVEL=debug
+//	LOG_COMPONENT_LEVELS=inmem-cache=debug,peers=warn
+//	LOG_SINKS=console:info:text,file:debug:json:logs/app.log   (type[:level[:format[:path]]])
+//	LOG_SAMPLING=100/100/1s   (first[/thereafter[/interval]] for every sink, or off; 0 or empty keeps
+//	                          the default, -1 means none: -1/10 samples from the first entry, 100/-1
+//	                          drops everything past the first 100)
+//	LOG_ASYNC=false  LOG_ASYNC_BUFFER=8192  LOG_ASYNC_WORKERS=2  LOG_ASYNC_OVERFLOW=drop-oldest
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+	if path := os.Getenv(configFileEnv); path != "" {
+		loaded, err := LoadConfig(path)
+		if err != nil {
+			return cfg, err
+		}
+		cfg = loaded
+	}
+	if level := os.Getenv(levelEnv); level != "" {
+		cfg.Level = level
+	}
+	if components := os.Getenv(componentLevelsEnv); components != "" {
+		cfg.Components = map[string]string{}
+		for _, pair := range strings.Split(components, ",") {
+			component, level, ok := strings.Cut(pair, "=")
+			if !ok {
+				return cfg, fmt.Errorf("%w: %s=%q", ErrInvalidEnv, componentLevelsEnv, components)
+			}
+			cfg.Components[strings.TrimSpace(component)] = strings.TrimSpace(level)
+		}
+	}
+	if sinks := os.Getenv(sinksEnv); sinks != "" {
+		cfg.Sinks = nil
+		for _, sink := range strings.Split(sinks, ",") {
+			parts := strings.SplitN(strings.TrimSpace(sink), ":", 4)
+			sinkConfig := SinkConfig{Type: parts[0]}
+			if len(parts) > 1 {
+				sinkConfig.Level = parts[1]
+			}
+			if len(parts) > 2 {
+				sinkConfig.Format = parts[2]
+			}
+			if len(parts) > 3 {
+				sinkConfig.Path = parts[3]
+			}
+			cfg.Sinks = append(cfg.Sinks, sinkConfig)
+		}
+	}
+	if sampling := os.Getenv(samplingEnv); sampling != "" {
+		samplingConfig, err := parseSamplingEnv(sampling)
+		if err != nil {
+			return cfg, err
+		}
+		for i := range cfg.Sinks {
+			cfg.Sinks[i].Sampling = samplingConfig
+		}
+	}
+	if async := os.Getenv(asyncEnv); async != "" {
+		enabled, err := strconv.ParseBool(async)
+		if err != nil {
+			return cfg, fmt.Errorf("%w: %s=%q", ErrInvalidEnv, asyncEnv, async)
+		}
+		cfg.Async.Disabled = !enabled
+	}
+	for env, target := range map[string]*int{asyncBufferEnv: &cfg.Async.BufferSize, asyncWorkersEnv: &cfg.Async.Workers} {
+		if value := os.Getenv(env); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return cfg, fmt.Errorf("%w: %s=%q", ErrInvalidEnv, env, value)
+			}
+			*target = parsed
+		}
+	}
+	if overflow := os.Getenv(asyncOverflowEnv); overflow != "" {
+		cfg.Async.Overflow = overflow
+	}
+	return cfg, nil
+}
+
+// Configure builds the dispatcher described by cfg and swaps it in. The previous dispatcher is
+// drained and closed. On error nothing changes.
+func Configure(cfg Config) error {
+	return l.configure(cfg)
+}
+
+func (lg *rootLogger) configure(cfg Config) error {
+	defaults := DefaultConfig()
+	if cfg.Level == "" {
+		cfg.Level = defaults.Level
+	}
+	if len(cfg.Sinks) == 0 {
+		cfg.Sinks = defaults.Sinks
+	}
+	// levels are validated before any sink file gets opened
+	for component, level := range cfg.Components {
+		if _, err := ParseLevel(level); err != nil {
+			return fmt.Errorf("component %s: %w", component, err)
+		}
+	}
+	if _, err := ParseLevel(cfg.Level); err != nil {
+		return err
+	}
+	policy, err := ParseOverflowPolicy(cfg.Async.Overflow)
+	if err != nil {
+		return err
+	}
+	dispatcher, err := NewDispatcher(cfg.Sinks)
+	if err != nil {
+		return err
+	}
+	if !cfg.Async.Disabled {
+		dispatcher = NewAsyncDispatcher(dispatcher,
+			WithBufferSize(cfg.Async.BufferSize),
+			WithWorkers(cfg.Async.Workers),
+			WithBatchSize(cfg.Async.BatchSize),
+			WithOverflowPolicy(policy, cfg.Async.SampleRate),
+		)
+	}
+	if err := ApplyLevels(LevelConfig{Level: cfg.Level, Components: cfg.Components}); err != nil {
+		closeDispatcher(context.Background(), dispatcher)
+		return err
+	}
+	if previous := lg.swap(dispatcher); previous != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := closeDispatcher(ctx, previous); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: closing previous dispatcher: %v\n", err)
+		}
+	}
+	return nil
+}
+
+func parseSamplingEnv(sampling string) (*SamplingConfig, error) {
+	if strings.EqualFold(sampling, "off") {
+		return nil, nil
+	}
+	invalid := fmt.Errorf("%w: %s=%q", ErrInvalidEnv, samplingEnv, sampling)
+	parts := strings.Split(sampling, "/")
+	if len(parts) > 3 {
+		return nil, invalid
+	}
+	samplingConfig := &SamplingConfig{}
+	for i, target := range []*int{&samplingConfig.First, &samplingConfig.Thereafter} {
+		if i >= len(parts) || parts[i] == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return nil, invalid
+		}
+		*target = parsed
+	}
+	if len(parts) == 3 {
+		samplingConfig.Interval = parts[2]
+	}
+	return samplingConfig, nil
+}
+
+type contextCloser interface {
+	Close(ctx context.Context) error
+}
+
+type closer interface {
+	Close() error
+}
+
+// closeDispatcher drains and closes dispatcher and whatever it wraps
+func closeDispatcher(ctx context.Context, dispatcher LogDispatcher) error {
+	switch d := dispatcher.(type) {
+	case *AsyncDispatcher:
+		// AsyncDispatcher.Close leaves next open for late entries, here nobody can reach it anymore.
+		// Workers that didn't drain in time may still be writing to next, so it stays open then.
+		if err := d.Close(ctx); err != nil {
+			return err
+		}
+		return closeDispatcher(ctx, d.next)
+	case contextCloser:
+		return d.Close(ctx)
+	case closer:
+		return d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends every log to a synchronous json file until the test ends and returns its path
+func captureLogs(t *testing.T) string {
+	t.Helper()
+	keepLevels(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+	err := Configure(Config{
+		Level: string(DEBUG),
+		Sinks: []SinkConfig{{Type: "file", Path: path, Format: "json"}},
+		Async: AsyncConfig{Disabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Configure(DefaultConfig()) })
+	return path
+}
+
+func readLogs(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(levelEnv, "warn")
+	t.Setenv(componentLevelsEnv, "inmem-cache=debug, peers=error")
+	t.Setenv(sinksEnv, "console:info:text,file:debug:json:logs/test.log")
+	t.Setenv(samplingEnv, "10/5/2s")
+	t.Setenv(asyncEnv, "false")
+	t.Setenv(asyncBufferEnv, "128")
+	t.Setenv(asyncOverflowEnv, "drop-oldest")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Level != "warn" || cfg.Components["inmem-cache"] != "debug" || cfg.Components["peers"] != "error" {
+		t.Fatalf("levels = %q %v", cfg.Level, cfg.Components)
+	}
+	if len(cfg.Sinks) != 2 || cfg.Sinks[1].Path != "logs/test.log" || cfg.Sinks[0].Format != "text" {
+		t.Fatalf("sinks = %+v", cfg.Sinks)
+	}
+	if sampling := cfg.Sinks[0].Sampling; sampling == nil || *sampling != (SamplingConfig{First: 10, Thereafter: 5, Interval: "2s"}) {
+		t.Fatalf("sampling = %+v", sampling)
+	}
+	if !cfg.Async.Disabled || cfg.Async.BufferSize != 128 || cfg.Async.Overflow != "drop-oldest" {
+		t.Fatalf("async = %+v", cfg.Async)
+	}
+}
+
+func TestConfigFromEnvRejectsBadValues(t *testing.T) {
+	for env, value := range map[string]string{
+		componentLevelsEnv: "inmem-cache",
+		asyncEnv:           "sometimes",
+		asyncWorkersEnv:    "two",
+		samplingEnv:        "1/2/3s/4",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, value)
+			if _, err := ConfigFromEnv(); !errors.Is(err, ErrInvalidEnv) {
+				t.Fatalf("%s=%q gave %v, want ErrInvalidEnv", env, value, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "logger.yaml")
+	os.WriteFile(yamlPath, []byte("level: debug\nsinks:\n  - type: file\n    path: app.log\n    rotation:\n      maxSizeMB: 10\n"), 0o644)
+	cfg, err := LoadConfig(yamlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Level != "debug" || len(cfg.Sinks) != 1 || cfg.Sinks[0].Rotation == nil || cfg.Sinks[0].Rotation.MaxSizeMB != 10 {
+		t.Fatalf("yaml config = %+v", cfg)
+	}
+
+	jsonPath := filepath.Join(dir, "logger.json")
+	os.WriteFile(jsonPath, []byte(`{"level":"info","async":{"disabled":true}}`), 0o644)
+	if cfg, err := LoadConfig(jsonPath); err != nil || cfg.Level != "info" || !cfg.Async.Disabled {
+		t.Fatalf("json config = %+v, %v", cfg, err)
+	}
+
+	tomlPath := filepath.Join(dir, "logger.toml")
+	os.WriteFile(tomlPath, nil, 0o644)
+	if _, err := LoadConfig(tomlPath); !errors.Is(err, ErrUnknownConfigFormat) {
+		t.Fatalf("toml config = %v, want ErrUnknownConfigFormat", err)
+	}
+}
+
+func TestConfigureKeepsTheLoggerOnError(t *testing.T) {
+	path := captureLogs(t)
+	bad := []Config{
+		{Level: "loud"},
+		{Components: map[string]string{"cache": "loud"}},
+		{Async: AsyncConfig{Overflow: "spill"}},
+		{Sinks: []SinkConfig{{Type: "syslog"}}},
+	}
+	for _, cfg := range bad {
+		if err := Configure(cfg); err == nil {
+			t.Fatalf("Configure(%+v) succeeded", cfg)
+		}
+	}
+	if GetLevel() != DEBUG {
+		t.Fatalf("level = %s, want the rejected configs to change nothing", GetLevel())
+	}
+	Dispatch(INFO, "still here")
+	if logs := readLogs(t, path); !strings.Contains(logs, "still here") {
+		t.Fatalf("entry missing from the configured sink:\n%s", logs)
+	}
+}
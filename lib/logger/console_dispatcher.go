@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -31,32 +32,63 @@ func colorForLevel(level Level) string {
 	}
 }
 
+// FormatLog renders the colored console line, fields are sorted by key
 func FormatLog(entry *LogEntry) string {
-	color := colorForLevel(entry.Level)
+	return formatText(entry, true)
+}
 
-	// Convert fields map to "key=value key=value"
-	var fields string
-	for k, v := range entry.Fields {
-		fields += fmt.Sprintf("%s=%s ", k, v)
+func formatText(entry *LogEntry, colored bool) string {
+	var b strings.Builder
+	if colored {
+		b.WriteString(colorForLevel(entry.Level))
 	}
-
-	return fmt.Sprintf(
-		"%s[%s] : [%s] : %s : %s",
-		color,
-		entry.Time.Format(time.RFC3339),
-		entry.Level,
-		entry.Msg,
-		fields,
-		ColorReset,
-	)
+	fmt.Fprintf(&b, "[%s] : [%s] : %s :", entry.Time.Format(time.RFC3339), entry.Level, entry.Msg)
+	for _, field := range sortedFields(entry) {
+		fmt.Fprintf(&b, " %s=%s", field.Key, field.String())
+	}
+	if colored {
+		b.WriteString(ColorReset)
+	}
+	return b.String()
 }
 
 type ConsoleDispatcher struct {
+	formatter Formatter
+}
+
+type OptionalDispatcherConfig func(d *dispatcherConfig)
+
+type dispatcherConfig struct {
+	formatter Formatter
+	rotation  []OptionalRotationConfig
+}
+
+// WithFormatter replaces the default format of a console or file dispatcher
+func WithFormatter(formatter Formatter) OptionalDispatcherConfig {
+	return func(d *dispatcherConfig) {
+		d.formatter = formatter
+	}
+}
+
+// WithRotation rotates the file of a file dispatcher, it is ignored by the console dispatcher
+func WithRotation(optionalRotationConfigs ...OptionalRotationConfig) OptionalDispatcherConfig {
+	return func(d *dispatcherConfig) {
+		d.rotation = append(d.rotation, optionalRotationConfigs...)
+	}
+}
+
+func getDispatcherConfig(formatter Formatter, optionalDispatcherConfigs []OptionalDispatcherConfig) *dispatcherConfig {
+	config := &dispatcherConfig{formatter: formatter}
+	for _, option := range optionalDispatcherConfigs {
+		option(config)
+	}
+	return config
 }
 
-func GetConsoleDispatcher() *ConsoleDispatcher {
-	return new(ConsoleDispatcher)
+func GetConsoleDispatcher(optionalDispatcherConfigs ...OptionalDispatcherConfig) *ConsoleDispatcher {
+	config := getDispatcherConfig(TextFormatter{}, optionalDispatcherConfigs)
+	return &ConsoleDispatcher{formatter: config.formatter}
 }
 func (cd *ConsoleDispatcher) Dispatch(l *LogEntry) {
-	fmt.Println(FormatLog(l))
+	fmt.Println(cd.formatter.Format(l))
 }
@@ -1,9 +1,180 @@
 package logger
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrUnknownSink = errors.New("unknown log sink type")
+
+const defaultLogFile = "logs/app.log"
+
+// SinkConfig describes one output of the logger, Level and Format fall back to the sink type defaults
+type SinkConfig struct {
+	Type   string `json:"type" yaml:"type"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+	Level  string `json:"level,omitempty" yaml:"level,omitempty"`
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Rotation only applies to file sinks
+	Rotation *RotationConfig `json:"rotation,omitempty" yaml:"rotation,omitempty"`
+	Sampling *SamplingConfig `json:"sampling,omitempty" yaml:"sampling,omitempty"`
+}
+
+// RotationConfig durations use the time.ParseDuration syntax, e.g. "24h"
+type RotationConfig struct {
+	MaxSizeMB  int    `json:"maxSizeMB,omitempty" yaml:"maxSizeMB,omitempty"`
+	Interval   string `json:"interval,omitempty" yaml:"interval,omitempty"`
+	MaxAge     string `json:"maxAge,omitempty" yaml:"maxAge,omitempty"`
+	MaxBackups int    `json:"maxBackups,omitempty" yaml:"maxBackups,omitempty"`
+	Compress   bool   `json:"compress,omitempty" yaml:"compress,omitempty"`
+}
+
+// SamplingConfig lets First entries per level and message through every Interval, then one in
+// Thereafter. Zero values take the NewSamplingDispatcher defaults, -1 means none: First -1 sends
+// no burst before sampling, Thereafter -1 drops everything past the burst.
+type SamplingConfig struct {
+	First      int    `json:"first,omitempty" yaml:"first,omitempty"`
+	Thereafter int    `json:"thereafter,omitempty" yaml:"thereafter,omitempty"`
+	Interval   string `json:"interval,omitempty" yaml:"interval,omitempty"`
+}
+
+func (sc *SamplingConfig) options() ([]OptionalSamplingConfig, error) {
+	var options []OptionalSamplingConfig
+	for _, count := range []struct {
+		value  int
+		option func(int) OptionalSamplingConfig
+	}{{sc.First, WithSampleFirst}, {sc.Thereafter, WithSampleThereafter}} {
+		switch {
+		case count.value > 0:
+			options = append(options, count.option(count.value))
+		case count.value < 0:
+			options = append(options, count.option(0))
+		}
+	}
+	if sc.Interval != "" {
+		interval, err := time.ParseDuration(sc.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("sampling: %w", err)
+		}
+		options = append(options, WithSampleInterval(interval))
+	}
+	return options, nil
+}
+
+func (rc *RotationConfig) options() ([]OptionalRotationConfig, error) {
+	options := []OptionalRotationConfig{
+		WithMaxSize(int64(rc.MaxSizeMB) << 20),
+		WithMaxBackups(rc.MaxBackups),
+		WithCompress(rc.Compress),
+	}
+	for _, duration := range []struct {
+		value  string
+		option func(time.Duration) OptionalRotationConfig
+	}{{rc.Interval, WithRotationInterval}, {rc.MaxAge, WithMaxAge}} {
+		if duration.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return nil, fmt.Errorf("rotation: %w", err)
+		}
+		options = append(options, duration.option(parsed))
+	}
+	return options, nil
+}
+
+// DefaultSinks is the colored console at INFO plus the ECS json file Filebeat tails at DEBUG,
+// both sampled with the NewSamplingDispatcher defaults so an error per request can't flood them
+func DefaultSinks() []SinkConfig {
+	return []SinkConfig{
+		{Type: "console", Level: string(INFO), Format: "text", Sampling: &SamplingConfig{}},
+		{Type: "file", Path: defaultLogFile, Level: string(DEBUG), Format: "ecs", Sampling: &SamplingConfig{}},
+	}
+}
+
+// NewDispatcher builds a dispatcher writing to every sink, each behind its own level threshold.
+// Sink levels apply on top of the global level, see SetLevel.
+func NewDispatcher(sinks []SinkConfig) (LogDispatcher, error) {
+	dispatchers := make([]LogDispatcher, 0, len(sinks))
+	for i, sink := range sinks {
+		dispatcher, err := newSinkDispatcher(sink)
+		if err != nil {
+			closeDispatcher(context.Background(), NewMultiDispatcher(dispatchers...))
+			return nil, fmt.Errorf("sink %d: %w", i, err)
+		}
+		dispatchers = append(dispatchers, dispatcher)
+	}
+	if len(dispatchers) == 1 {
+		return dispatchers[0], nil
+	}
+	return NewMultiDispatcher(dispatchers...), nil
+}
+
+func newSinkDispatcher(sink SinkConfig) (LogDispatcher, error) {
+	var optionalDispatcherConfigs []OptionalDispatcherConfig
+	if sink.Format != "" {
+		formatter, err := FormatterByName(sink.Format)
+		if err != nil {
+			return nil, err
+		}
+		optionalDispatcherConfigs = append(optionalDispatcherConfigs, WithFormatter(formatter))
+	}
+	level := Level("")
+	if sink.Level != "" {
+		parsed, err := ParseLevel(sink.Level)
+		if err != nil {
+			return nil, err
+		}
+		level = parsed
+	}
+	var sampling []OptionalSamplingConfig
+	if sink.Sampling != nil {
+		parsed, err := sink.Sampling.options()
+		if err != nil {
+			return nil, err
+		}
+		sampling = parsed
+	}
+	var dispatcher LogDispatcher
+	switch sink.Type {
+	case "console":
+		dispatcher = GetConsoleDispatcher(optionalDispatcherConfigs...)
+	case "file":
+		if sink.Rotation != nil {
+			rotation, err := sink.Rotation.options()
+			if err != nil {
+				return nil, err
+			}
+			optionalDispatcherConfigs = append(optionalDispatcherConfigs, WithRotation(rotation...))
+		}
+		path := sink.Path
+		if path == "" {
+			path = defaultLogFile
+		}
+		fileDispatcher, err := NewFileDispatcher(path, optionalDispatcherConfigs...)
+		if err != nil {
+			return nil, err
+		}
+		dispatcher = fileDispatcher
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSink, sink.Type)
+	}
+	if sink.Sampling != nil {
+		dispatcher = NewSamplingDispatcher(dispatcher, sampling...)
+	}
+	if level == "" {
+		return dispatcher, nil
+	}
+	// the level check comes first, entries it drops don't count against the sampling budget
+	return NewLevelDispatcher(dispatcher, level), nil
+}
+
 func GetDispatcher() LogDispatcher {
-	// Should be changed to switch case
-	//if _, ok := config["fileDispatcher"]; ok {
-	//	return GetFileDispatcher("asfasf")
-	//}
-	return GetFileDispatcher("logs/app.log")
+	dispatcher, err := NewDispatcher(DefaultSinks())
+	if err != nil {
+		return GetConsoleDispatcher()
+	}
+	return dispatcher
 }
@@ -0,0 +1,185 @@
+package logger
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+)
+
+type FieldKind uint8
+
+const (
+	StringKind FieldKind = iota
+	IntKind
+	FloatKind
+	BoolKind
+	DurationKind
+	TimeKind
+	ErrorKind
+	ObjectKind
+)
+
+// Field is a typed key/value pair. The constructors store numbers, bools, durations and times
+// inline so building a Field never allocates.
+type Field struct {
+	Key   string
+	Kind  FieldKind
+	str   string
+	num   int64
+	float float64
+	obj   any
+}
+
+func String(key string, value string) Field {
+	return Field{Key: key, Kind: StringKind, str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Kind: IntKind, num: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Kind: IntKind, num: value}
+}
+
+func Float(key string, value float64) Field {
+	return Field{Key: key, Kind: FloatKind, float: value}
+}
+
+func Bool(key string, value bool) Field {
+	field := Field{Key: key, Kind: BoolKind}
+	if value {
+		field.num = 1
+	}
+	return field
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Kind: DurationKind, num: int64(value)}
+}
+
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, Kind: TimeKind, num: value.UnixNano(), obj: value.Location()}
+}
+
+// Err is the error under the key "error", a nil error is logged as an empty string
+func Err(err error) Field {
+	return Field{Key: "error", Kind: ErrorKind, obj: err}
+}
+
+// Object nests value, it is serialized with encoding/json
+func Object(key string, value any) Field {
+	return Field{Key: key, Kind: ObjectKind, obj: value}
+}
+
+// Value returns the field as its Go type
+func (f Field) Value() any {
+	switch f.Kind {
+	case IntKind:
+		return f.num
+	case FloatKind:
+		return f.float
+	case BoolKind:
+		return f.num == 1
+	case DurationKind:
+		return time.Duration(f.num)
+	case TimeKind:
+		return f.time()
+	case ErrorKind:
+		return f.errString()
+	case ObjectKind:
+		return f.obj
+	default:
+		return f.str
+	}
+}
+
+func (f Field) time() time.Time {
+	t := time.Unix(0, f.num)
+	if location, ok := f.obj.(*time.Location); ok && location != nil {
+		t = t.In(location)
+	}
+	return t
+}
+
+func (f Field) errString() string {
+	if err, ok := f.obj.(error); ok && err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// String renders the value for text formats, durations use time.Duration.String
+func (f Field) String() string {
+	switch f.Kind {
+	case IntKind:
+		return strconv.FormatInt(f.num, 10)
+	case FloatKind:
+		return strconv.FormatFloat(f.float, 'f', -1, 64)
+	case BoolKind:
+		return strconv.FormatBool(f.num == 1)
+	case DurationKind:
+		return time.Duration(f.num).String()
+	case TimeKind:
+		return f.time().Format(time.RFC3339Nano)
+	case ErrorKind:
+		return f.errString()
+	case ObjectKind:
+		encoded, err := json.Marshal(f.obj)
+		if err != nil {
+			return err.Error()
+		}
+		return string(encoded)
+	default:
+		return f.str
+	}
+}
+
+// jsonValue is what the json formatters encode, durations become nanoseconds like ECS event.duration
+func (f Field) jsonValue() any {
+	switch f.Kind {
+	case FloatKind:
+		// encoding/json rejects NaN and infinities
+		if math.IsNaN(f.float) || math.IsInf(f.float, 0) {
+			return f.String()
+		}
+		return f.float
+	case DurationKind:
+		return f.num
+	default:
+		return f.Value()
+	}
+}
+
+// Fields keeps insertion order, a key appears at most once
+type Fields []Field
+
+// Get returns the field stored under key
+func (fs Fields) Get(key string) (Field, bool) {
+	for _, field := range fs {
+		if field.Key == key {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// set replaces the field with the same key or appends it
+func (fs Fields) set(field Field) Fields {
+	for i := range fs {
+		if fs[i].Key == field.Key {
+			fs[i] = field
+			return fs
+		}
+	}
+	return append(fs, field)
+}
+
+func (fs Fields) MarshalJSON() ([]byte, error) {
+	object := make(map[string]any, len(fs))
+	for _, field := range fs {
+		object[field.Key] = field.jsonValue()
+	}
+	return json.Marshal(object)
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFieldsKeepTheirType(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	fields := Fields{
+		Int("count", 3),
+		Float("ratio", 0.5),
+		Bool("hit", true),
+		Duration("took", 1500*time.Millisecond),
+		Time("at", at),
+		Err(nil),
+		Object("tags", []string{"a", "b"}),
+	}
+	want := map[string]string{
+		"count": "3",
+		"ratio": "0.5",
+		"hit":   "true",
+		"took":  "1.5s",
+		"at":    "2026-03-01T12:30:00+01:00",
+		"error": "",
+		"tags":  `["a","b"]`,
+	}
+	for _, field := range fields {
+		if got := field.String(); got != want[field.Key] {
+			t.Errorf("%s = %q, want %q", field.Key, got, want[field.Key])
+		}
+	}
+	if got := Time("at", at).Value().(time.Time); !got.Equal(at) || got.Location() != at.Location() {
+		t.Fatalf("time value = %v, want %v", got, at)
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	json.Unmarshal(encoded, &decoded)
+	if decoded["count"] != float64(3) || decoded["hit"] != true || decoded["took"] != float64(1500*time.Millisecond) {
+		t.Fatalf("json = %s, want native numbers, bools and nanosecond durations", encoded)
+	}
+}
+
+func TestNonFiniteFloatsStillEncode(t *testing.T) {
+	encoded, err := json.Marshal(Fields{Float("ratio", math.NaN()), Float("limit", math.Inf(1))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"limit":"+Inf","ratio":"NaN"}` {
+		t.Fatalf("json = %s", encoded)
+	}
+}
+
+func TestWithFieldMapKeepsExistingFields(t *testing.T) {
+	entry := WithEntry().
+		WithFields(Int("attempt", 1), String("key", "a")).
+		WithFieldMap(map[string]string{"key": "b", "node": "n1"})
+	if len(entry.Fields) != 3 {
+		t.Fatalf("fields = %v, want attempt, key and node", entry.Fields)
+	}
+	if key, _ := entry.Fields.Get("key"); key.String() != "b" || entry.Fields[1].Key != "key" {
+		t.Fatalf("key = %v, want b replaced in place", entry.Fields)
+	}
+	if attempt, ok := entry.Fields.Get("attempt"); !ok || attempt.Kind != IntKind {
+		t.Fatal("WithFieldMap dropped the typed field")
+	}
+}
+
+// discardDispatcher drops every entry, so allocation counts only cover building them
+type discardDispatcher struct{}
+
+func (discardDispatcher) Dispatch(*LogEntry) {}
+
+func TestChildLoggerFieldsDoNotAllocatePerField(t *testing.T) {
+	keepLevels(t)
+	SetLevel(INFO)
+	previous := l.swap(discardDispatcher{})
+	t.Cleanup(func() { l.swap(previous) })
+	lg := Named("cache").With(String("cache", "todo"))
+	err := errors.New("timeout")
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		lg.Debug("filtered", String("key", "k"), Int("attempt", 2), Err(err))
+	}); allocs != 0 {
+		t.Fatalf("a filtered child logger call allocated %v times", allocs)
+	}
+	one := testing.AllocsPerRun(100, func() {
+		lg.Info("miss", String("key", "k"))
+	})
+	six := testing.AllocsPerRun(100, func() {
+		lg.Info("miss", String("key", "k"), Int("attempt", 2), Int64("size", 3),
+			Float("ratio", 0.5), Duration("took", time.Second), Err(err))
+	})
+	if six != one {
+		t.Fatalf("six typed fields took %v allocations, one took %v, want the same", six, one)
+	}
+}
@@ -4,26 +4,84 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync/atomic"
+	"time"
 )
 
 type FileDispatcher struct {
-	logger   *log.Logger
-	filePath string
+	logger    *log.Logger
+	filePath  string
+	file      *RotatingFile
+	formatter Formatter
+	// writeStarted is the unix nano start of the write in progress, 0 when idle
+	writeStarted atomic.Int64
 }
 
-func GetFileDispatcher(filePath string) *FileDispatcher {
-	// Create logs folder if not exists
-	os.MkdirAll("logs", 0755)
-
-	// Create/open log file
-	f, _ := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// NewFileDispatcher appends one formatted entry per line to filePath, creating it and its directory when missing.
+// The file only rotates when WithRotation is given.
+func NewFileDispatcher(filePath string, optionalDispatcherConfigs ...OptionalDispatcherConfig) (*FileDispatcher, error) {
+	config := getDispatcherConfig(JSONFormatter{}, optionalDispatcherConfigs)
+	f, err := NewRotatingFile(filePath, config.rotation...)
+	if err != nil {
+		return nil, err
+	}
 	return &FileDispatcher{
-		filePath: filePath,
-		logger:   log.New(f, "", log.LstdFlags),
+		filePath:  filePath,
+		file:      f,
+		logger:    log.New(f, "", 0),
+		formatter: config.formatter,
+	}, nil
+}
+
+// GetFileDispatcher is NewFileDispatcher falling back to stderr when the file can't be opened
+func GetFileDispatcher(filePath string, optionalDispatcherConfigs ...OptionalDispatcherConfig) *FileDispatcher {
+	fd, err := NewFileDispatcher(filePath, optionalDispatcherConfigs...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logger: %v, writing %s to stderr\n", err, filePath)
+		config := getDispatcherConfig(JSONFormatter{}, optionalDispatcherConfigs)
+		return &FileDispatcher{
+			filePath:  filePath,
+			logger:    log.New(os.Stderr, "", 0),
+			formatter: config.formatter,
+		}
 	}
+	return fd
 }
 
 func (fd *FileDispatcher) Dispatch(l *LogEntry) {
-	fd.logger.Println(l.string())
-	fmt.Println("Printing to the file dispatcher ", l.string())
+	fd.writeStarted.Store(time.Now().UnixNano())
+	fd.logger.Println(fd.formatter.Format(l))
+	fd.writeStarted.Store(0)
+}
+
+// DispatchBatch writes the entries back to back, the whole batch counts as one write for StuckWriterCheck
+func (fd *FileDispatcher) DispatchBatch(entries []*LogEntry) {
+	fd.writeStarted.Store(time.Now().UnixNano())
+	for _, entry := range entries {
+		fd.logger.Println(fd.formatter.Format(entry))
+	}
+	fd.writeStarted.Store(0)
+}
+
+func (fd *FileDispatcher) Close() error {
+	if fd.file == nil {
+		return nil
+	}
+	return fd.file.Close()
+}
+
+// Reopen reopens the file after an external logrotate moved it
+func (fd *FileDispatcher) Reopen() error {
+	if fd.file == nil {
+		return nil
+	}
+	return fd.file.Reopen()
+}
+
+func (fd *FileDispatcher) writingSince() time.Time {
+	started := fd.writeStarted.Load()
+	if started == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, started)
 }
@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrUnknownFormat = errors.New("unknown log format")
+
+// Formatter renders an entry as a single line without the trailing newline
+type Formatter interface {
+	Format(l *LogEntry) string
+}
+
+// FormatterFunc adapts a plain function to Formatter
+type FormatterFunc func(l *LogEntry) string
+
+func (f FormatterFunc) Format(l *LogEntry) string {
+	return f(l)
+}
+
+// JSONFormatter writes the entry as the json object LogEntry marshals to
+type JSONFormatter struct{}
+
+func (JSONFormatter) Format(l *LogEntry) string {
+	return l.string()
+}
+
+// TextFormatter writes the console line of FormatLog, colored by level unless NoColor is set
+type TextFormatter struct {
+	NoColor bool
+}
+
+func (t TextFormatter) Format(l *LogEntry) string {
+	return formatText(l, !t.NoColor)
+}
+
+// ecsVersion is the Elastic Common Schema version ECSFormatter documents follow
+const ecsVersion = "8.11.0"
+
+// ECSFormatter writes an Elastic Common Schema json document. Fields keep their json type at the
+// top level so Elasticsearch maps them natively, an Err field becomes error.message.
+type ECSFormatter struct{}
+
+func (ECSFormatter) Format(l *LogEntry) string {
+	document := make(map[string]any, len(l.Fields)+4)
+	for _, field := range l.Fields {
+		if field.Kind == ErrorKind {
+			document["error.message"] = field.errString()
+			continue
+		}
+		document[field.Key] = field.jsonValue()
+	}
+	// the ECS keys win over fields with the same name
+	document["@timestamp"] = l.Time.UTC().Format(time.RFC3339Nano)
+	document["log.level"] = l.Level
+	document["message"] = l.Msg
+	document["ecs.version"] = ecsVersion
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Sprintf(`{"@timestamp":%q,"log.level":"error","message":%q,"ecs.version":%q}`,
+			l.Time.UTC().Format(time.RFC3339Nano), "log entry not serializable: "+err.Error(), ecsVersion)
+	}
+	return string(encoded)
+}
+
+// LogfmtFormatter writes time, level and msg followed by the fields sorted by key
+type LogfmtFormatter struct{}
+
+func (LogfmtFormatter) Format(l *LogEntry) string {
+	var b strings.Builder
+	writeLogfmtPair(&b, "time", l.Time.UTC().Format(time.RFC3339Nano))
+	writeLogfmtPair(&b, "level", string(l.Level))
+	writeLogfmtPair(&b, "msg", l.Msg)
+	for _, field := range sortedFields(l) {
+		writeLogfmtPair(&b, field.Key, field.String())
+	}
+	return b.String()
+}
+
+func writeLogfmtPair(b *strings.Builder, key string, value string) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(key)
+	b.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\t\n\r\\") || !utf8.ValidString(value) {
+		b.WriteString(strconv.Quote(value))
+		return
+	}
+	b.WriteString(value)
+}
+
+func sortedFields(l *LogEntry) Fields {
+	sorted := make(Fields, len(l.Fields))
+	copy(sorted, l.Fields)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+var formatters = map[string]Formatter{
+	"json":   JSONFormatter{},
+	"ecs":    ECSFormatter{},
+	"logfmt": LogfmtFormatter{},
+	"text":   TextFormatter{},
+	"plain":  TextFormatter{NoColor: true},
+}
+
+// FormatterByName returns the formatter sink configs refer to by name
+func FormatterByName(name string) (Formatter, error) {
+	formatter, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+	return formatter, nil
+}
@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var formatTime = time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+
+func formatEntry() *LogEntry {
+	return WithEntry().
+		WithMessage("cache miss").
+		WithFields(String("key", "user 1"), Int("attempt", 2), Err(errors.New("timeout"))).
+		withTime(formatTime).
+		withLevel(WARN)
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	got := LogfmtFormatter{}.Format(formatEntry())
+	want := `time=2026-03-01T12:30:00Z level=warn msg="cache miss" attempt=2 error=timeout key="user 1"`
+	if got != want {
+		t.Fatalf("logfmt =\n%s\nwant\n%s", got, want)
+	}
+	if got := (LogfmtFormatter{}).Format(WithEntry().WithField("empty", "").withTime(formatTime)); !strings.Contains(got, `empty=""`) {
+		t.Fatalf("empty value not quoted: %s", got)
+	}
+}
+
+func TestECSFormatter(t *testing.T) {
+	entry := formatEntry().WithFields(String("message", "shadowed"))
+	var document map[string]any
+	if err := json.Unmarshal([]byte(ECSFormatter{}.Format(entry)), &document); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"@timestamp":    "2026-03-01T12:30:00Z",
+		"log.level":     "warn",
+		"message":       "cache miss",
+		"ecs.version":   ecsVersion,
+		"error.message": "timeout",
+		"key":           "user 1",
+		"attempt":       float64(2),
+	}
+	for key, value := range want {
+		if document[key] != value {
+			t.Errorf("%s = %v, want %v", key, document[key], value)
+		}
+	}
+	if _, ok := document["error"]; ok {
+		t.Error("error field kept next to error.message")
+	}
+}
+
+func TestTextFormatter(t *testing.T) {
+	plain := TextFormatter{NoColor: true}.Format(formatEntry())
+	if want := "[2026-03-01T12:30:00Z] : [warn] : cache miss : attempt=2 error=timeout key=user 1"; plain != want {
+		t.Fatalf("plain =\n%s\nwant\n%s", plain, want)
+	}
+	if colored := FormatLog(formatEntry()); colored != ColorYellow+plain+ColorReset {
+		t.Fatalf("FormatLog = %q", colored)
+	}
+}
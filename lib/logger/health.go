@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inmem/lib/health"
+	"time"
+)
+
+var ErrWriterStuck = errors.New("log writer is stuck")
+
+// writeTracker is implemented by dispatchers that know when their current write started
+type writeTracker interface {
+	writingSince() time.Time
+}
+
+// StuckWriterCheck is down while a single write of the active dispatcher takes longer than threshold,
+// dispatchers that don't track their writes are always up
+func StuckWriterCheck(threshold time.Duration) health.Check {
+	return func(ctx context.Context) error {
+		tracker, ok := l.dispatcher().(writeTracker)
+		if !ok {
+			return nil
+		}
+		since := tracker.writingSince()
+		if since.IsZero() {
+			return nil
+		}
+		if blocked := time.Since(since); blocked > threshold {
+			return fmt.Errorf("%w: write blocked for %s", ErrWriterStuck, blocked.Round(time.Millisecond))
+		}
+		return nil
+	}
+}
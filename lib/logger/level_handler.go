@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+var ErrLevelAuthRequired = errors.New("level changes need an authorizer, see WithLevelAuth")
+
+type levelHandlerConfig struct {
+	authorize func(r *http.Request) error
+}
+
+type OptionalLevelHandlerConfig func(c *levelHandlerConfig)
+
+// WithLevelAuth lets a PUT through when authorize returns nil, its error is sent back with 401
+func WithLevelAuth(authorize func(r *http.Request) error) OptionalLevelHandlerConfig {
+	return func(c *levelHandlerConfig) {
+		c.authorize = authorize
+	}
+}
+
+// LevelHandler serves the levels as a LevelConfig, GET reads them and PUT replaces them.
+// A PUT without level keeps the global level, one without components clears the overrides.
+// PUT answers 403 unless the handler has an authorizer, see WithLevelAuth.
+func LevelHandler(optionalLevelHandlerConfigs ...OptionalLevelHandlerConfig) http.Handler {
+	config := &levelHandlerConfig{}
+	for _, option := range optionalLevelHandlerConfigs {
+		option(config)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			if config.authorize == nil {
+				http.Error(w, ErrLevelAuthRequired.Error(), http.StatusForbidden)
+				return
+			}
+			if err := config.authorize(r); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			var levels LevelConfig
+			if err := json.NewDecoder(r.Body).Decode(&levels); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := ApplyLevels(levels); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		levels := LevelConfig{Level: string(GetLevel()), Components: map[string]string{}}
+		for component, level := range ComponentLevels() {
+			levels.Components[component] = string(level)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(levels)
+	})
+}
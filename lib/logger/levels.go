@@ -0,0 +1,254 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var ErrUnknownLevel = errors.New("unknown log level")
+
+// componentField is the entry field DispatchComponent tags entries with, component overrides match on it
+const componentField = "component"
+
+const defaultLevel = INFO
+
+// severity orders the levels, entries below the threshold are dropped before they are built
+func (lv Level) severity() int32 {
+	switch lv {
+	case DEBUG:
+		return 0
+	case INFO:
+		return 1
+	case WARN:
+		return 2
+	case ERROR:
+		return 3
+	default:
+		return 1
+	}
+}
+
+func ParseLevel(level string) (Level, error) {
+	switch parsed := Level(strings.ToLower(strings.TrimSpace(level))); parsed {
+	case DEBUG, INFO, WARN, ERROR:
+		return parsed, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownLevel, level)
+}
+
+// levelState is replaced as a whole on every change so the hot path reads it with a single atomic load
+type levelState struct {
+	global     Level
+	components map[string]Level
+	// floor is the lowest threshold of global and every override, anything below it is filtered
+	// without looking at the component
+	floor int32
+}
+
+var (
+	levels   = defaultLevels()
+	levelsMu sync.Mutex
+)
+
+// defaultLevels is a package var initializer rather than init so the logger var, which applies
+// its config while initializing, always finds it set
+func defaultLevels() *atomic.Pointer[levelState] {
+	state := &atomic.Pointer[levelState]{}
+	state.Store(&levelState{global: defaultLevel, components: map[string]Level{}, floor: defaultLevel.severity()})
+	return state
+}
+
+func updateLevels(update func(global Level, components map[string]Level) Level) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	current := levels.Load()
+	components := make(map[string]Level, len(current.components))
+	for component, level := range current.components {
+		components[component] = level
+	}
+	global := update(current.global, components)
+	floor := global.severity()
+	for _, level := range components {
+		floor = min(floor, level.severity())
+	}
+	levels.Store(&levelState{global: global, components: components, floor: floor})
+}
+
+// SetLevel sets the global minimum level
+func SetLevel(level Level) {
+	updateLevels(func(_ Level, _ map[string]Level) Level {
+		return level
+	})
+}
+
+func GetLevel() Level {
+	return levels.Load().global
+}
+
+// SetComponentLevel overrides the global level for entries of component
+func SetComponentLevel(component string, level Level) {
+	updateLevels(func(global Level, components map[string]Level) Level {
+		components[component] = level
+		return global
+	})
+}
+
+func ResetComponentLevel(component string) {
+	updateLevels(func(global Level, components map[string]Level) Level {
+		delete(components, component)
+		return global
+	})
+}
+
+// ComponentLevels returns a copy of the overrides
+func ComponentLevels() map[string]Level {
+	current := levels.Load()
+	components := make(map[string]Level, len(current.components))
+	for component, level := range current.components {
+		components[component] = level
+	}
+	return components
+}
+
+// Enabled reports whether an entry at level would be written, use it to skip building expensive entries
+func Enabled(level Level) bool {
+	return EnabledFor("", level)
+}
+
+func EnabledFor(component string, level Level) bool {
+	current := levels.Load()
+	severity := level.severity()
+	if severity < current.floor {
+		return false
+	}
+	if override, ok := current.components[component]; ok && component != "" {
+		return severity >= override.severity()
+	}
+	return severity >= current.global.severity()
+}
+
+// LevelConfig is the file format of LoadLevels and the body of the level http endpoint
+type LevelConfig struct {
+	Level      string            `json:"level,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+// ApplyLevels sets the global level when given and replaces every component override
+func ApplyLevels(config LevelConfig) error {
+	var global Level
+	if config.Level != "" {
+		parsed, err := ParseLevel(config.Level)
+		if err != nil {
+			return err
+		}
+		global = parsed
+	}
+	components := make(map[string]Level, len(config.Components))
+	for component, level := range config.Components {
+		parsed, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		components[component] = parsed
+	}
+	updateLevels(func(current Level, overrides map[string]Level) Level {
+		clear(overrides)
+		for component, level := range components {
+			overrides[component] = level
+		}
+		if global == "" {
+			return current
+		}
+		return global
+	})
+	return nil
+}
+
+// LoadLevels applies a json LevelConfig file
+func LoadLevels(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config LevelConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return err
+	}
+	return ApplyLevels(config)
+}
+
+// ReloadLevelsOnSignal loads path now and again on every SIGHUP, reload errors are logged and
+// keep the previous levels
+func ReloadLevelsOnSignal(path string) error {
+	if err := LoadLevels(path); err != nil {
+		return err
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := LoadLevels(path); err != nil {
+				Dispatch(ERROR, "log level reload failed: "+err.Error())
+				continue
+			}
+			Dispatch(INFO, "log levels reloaded from "+path)
+		}
+	}()
+	return nil
+}
+
+// LevelDispatcher drops entries below its own minimum level before they reach next
+type LevelDispatcher struct {
+	next  LogDispatcher
+	level atomic.Int32
+}
+
+func NewLevelDispatcher(next LogDispatcher, level Level) *LevelDispatcher {
+	dispatcher := &LevelDispatcher{next: next}
+	dispatcher.SetLevel(level)
+	return dispatcher
+}
+
+func (ld *LevelDispatcher) SetLevel(level Level) {
+	ld.level.Store(level.severity())
+}
+
+func (ld *LevelDispatcher) Dispatch(l *LogEntry) {
+	if l.Level.severity() < ld.level.Load() {
+		return
+	}
+	ld.next.Dispatch(l)
+}
+
+func (ld *LevelDispatcher) DispatchBatch(entries []*LogEntry) {
+	threshold := ld.level.Load()
+	kept := make([]*LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Level.severity() >= threshold {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) > 0 {
+		dispatchBatch(ld.next, kept)
+	}
+}
+
+func (ld *LevelDispatcher) Close(ctx context.Context) error {
+	return closeDispatcher(ctx, ld.next)
+}
+
+func (ld *LevelDispatcher) writingSince() time.Time {
+	if tracker, ok := ld.next.(writeTracker); ok {
+		return tracker.writingSince()
+	}
+	return time.Time{}
+}
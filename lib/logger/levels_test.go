@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// keepLevels restores the process wide levels when the test ends
+func keepLevels(t *testing.T) {
+	t.Helper()
+	global, components := GetLevel(), ComponentLevels()
+	t.Cleanup(func() {
+		config := LevelConfig{Level: string(global), Components: map[string]string{}}
+		for component, level := range components {
+			config.Components[component] = string(level)
+		}
+		ApplyLevels(config)
+	})
+}
+
+func TestComponentLevelsOverrideTheGlobalLevel(t *testing.T) {
+	keepLevels(t)
+	if err := ApplyLevels(LevelConfig{Level: "warn", Components: map[string]string{"cache": "debug"}}); err != nil {
+		t.Fatal(err)
+	}
+	if Enabled(INFO) || !Enabled(ERROR) {
+		t.Fatal("global warn level not applied")
+	}
+	if !EnabledFor("cache", DEBUG) || EnabledFor("http", INFO) {
+		t.Fatal("component override not applied")
+	}
+	if err := ApplyLevels(LevelConfig{Level: "loud"}); !errors.Is(err, ErrUnknownLevel) {
+		t.Fatalf("ApplyLevels = %v, want ErrUnknownLevel", err)
+	}
+	if GetLevel() != WARN {
+		t.Fatalf("level = %s after a rejected config, want warn kept", GetLevel())
+	}
+}
+
+func TestFilteredDispatchDoesNotAllocate(t *testing.T) {
+	keepLevels(t)
+	if err := ApplyLevels(LevelConfig{Level: "warn", Components: map[string]string{"peers": "error"}}); err != nil {
+		t.Fatal(err)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { Dispatch(DEBUG, "below the global level") }); allocs != 0 {
+		t.Fatalf("Dispatch below the level allocated %v times", allocs)
+	}
+	entry := WithEntry().WithMessage("below the component level").WithField(componentField, "peers")
+	if allocs := testing.AllocsPerRun(100, func() { Dispatch(WARN, entry) }); allocs != 0 {
+		t.Fatalf("Dispatch below the component level allocated %v times", allocs)
+	}
+}
+
+func putLevels(handler http.Handler, body string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestLevelHandlerNeedsAnAuthorizerForPut(t *testing.T) {
+	keepLevels(t)
+	SetLevel(INFO)
+	if w := putLevels(LevelHandler(), `{"level":"debug"}`, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("PUT without WithLevelAuth = %d, want 403", w.Code)
+	}
+	if GetLevel() != INFO {
+		t.Fatalf("level = %s, want the rejected PUT to change nothing", GetLevel())
+	}
+
+	handler := LevelHandler(WithLevelAuth(func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			return errors.New("bad token")
+		}
+		return nil
+	}))
+	if w := putLevels(handler, `{"level":"debug"}`, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("PUT with a wrong token = %d, want 401", w.Code)
+	}
+	if w := putLevels(handler, `{"level":"debug"}`, "secret"); w.Code != http.StatusOK || GetLevel() != DEBUG {
+		t.Fatalf("PUT with the token = %d, level %s, want debug applied", w.Code, GetLevel())
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/loglevel", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"level":"debug"`) {
+		t.Fatalf("GET = %d %s", w.Code, w.Body)
+	}
+}
@@ -2,13 +2,14 @@ package logger
 
 import (
 	"encoding/json"
+	"sort"
 	"time"
 )
 
 type Level string
 
 type LogEntry struct {
-	Fields map[string]string
+	Fields Fields
 	Msg    string
 	Time   time.Time
 	Level  Level
@@ -26,12 +27,28 @@ func (l *LogEntry) WithMessage(msg string) *LogEntry {
 	return l
 }
 func (l *LogEntry) WithField(key string, value string) *LogEntry {
-	l.Fields[key] = value
+	l.Fields = l.Fields.set(String(key, value))
 	return l
 }
 
+// WithFields adds typed fields, a field replaces an earlier one with the same key
+func (l *LogEntry) WithFields(fields ...Field) *LogEntry {
+	for _, field := range fields {
+		l.Fields = l.Fields.set(field)
+	}
+	return l
+}
+
+// WithFieldMap adds every pair of value as a string field, in key order, keeping the existing fields
 func (l *LogEntry) WithFieldMap(value map[string]string) *LogEntry {
-	l.Fields = value
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		l.Fields = l.Fields.set(String(key, value[key]))
+	}
 	return l
 }
 func (l *LogEntry) withTime(time time.Time) *LogEntry {
@@ -50,7 +67,5 @@ func (l *LogEntry) string() string {
 }
 
 func WithEntry() *LogEntry {
-	return &LogEntry{
-		Fields: map[string]string{},
-	}
+	return &LogEntry{}
 }
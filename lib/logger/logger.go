@@ -1,6 +1,10 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
 	"time"
 )
 
@@ -12,12 +16,50 @@ type LogDispatcher interface {
 	Dispatch(l *LogEntry)
 }
 
-type Logger struct {
+// rootLogger guards the dispatcher so Configure can swap it while entries are being dispatched
+type rootLogger struct {
+	mu            sync.RWMutex
 	logDispatcher LogDispatcher
 }
 
-var l = &Logger{
-	logDispatcher: GetDispatcher(),
+var l = newLogger()
+
+// newLogger configures the package logger from the environment, see ConfigFromEnv. A broken
+// config is reported on stderr and the defaults are used instead.
+func newLogger() *rootLogger {
+	lg := &rootLogger{}
+	cfg, err := ConfigFromEnv()
+	if err == nil {
+		err = lg.configure(cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logger: invalid config, using defaults: %v\n", err)
+		if err := lg.configure(DefaultConfig()); err != nil {
+			lg.logDispatcher = GetConsoleDispatcher()
+		}
+	}
+	return lg
+}
+
+func (lg *rootLogger) dispatch(logEntry *LogEntry) {
+	lg.mu.RLock()
+	defer lg.mu.RUnlock()
+	lg.logDispatcher.Dispatch(logEntry)
+}
+
+func (lg *rootLogger) dispatcher() LogDispatcher {
+	lg.mu.RLock()
+	defer lg.mu.RUnlock()
+	return lg.logDispatcher
+}
+
+// swap installs dispatcher and returns the previous one, nobody is dispatching to it once swap returns
+func (lg *rootLogger) swap(dispatcher LogDispatcher) LogDispatcher {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	previous := lg.logDispatcher
+	lg.logDispatcher = dispatcher
+	return previous
 }
 
 func getLogEntry[T LogEntryType](le T) *LogEntry {
@@ -32,8 +74,62 @@ func getLogEntry[T LogEntryType](le T) *LogEntry {
 }
 
 func Dispatch[T LogEntryType](logLevel Level, le T) {
+	// entries below every threshold are dropped before anything is allocated
+	if logLevel.severity() < levels.Load().floor {
+		return
+	}
+	logEntry := getLogEntry(le)
+	component, _ := logEntry.Fields.Get(componentField)
+	if !EnabledFor(component.str, logLevel) {
+		return
+	}
+	logEntry = logEntry.
+		withTime(time.Now()).
+		withLevel(logLevel)
+	l.dispatch(logEntry)
+}
+
+// DispatchComponent tags the entry with component so per-component levels apply to it
+func DispatchComponent[T LogEntryType](component string, logLevel Level, le T) {
+	if !EnabledFor(component, logLevel) {
+		return
+	}
 	logEntry := getLogEntry(le).
+		WithField(componentField, component).
 		withTime(time.Now()).
 		withLevel(logLevel)
-	l.logDispatcher.Dispatch(logEntry)
+	l.dispatch(logEntry)
+}
+
+// Flush waits for the entries queued by an async dispatcher to be written
+func Flush(ctx context.Context) error {
+	if async, ok := l.dispatcher().(*AsyncDispatcher); ok {
+		return async.Flush(ctx)
+	}
+	return nil
+}
+
+// ShutdownHook drains an async dispatcher and closes the sinks, so sampling summaries are written
+// and files are closed, register it with shutdown.AddHook. Entries logged after it ran only reach
+// the sinks that don't need closing, like the console.
+func ShutdownHook(ctx context.Context) {
+	dispatcher := l.dispatcher()
+	if async, ok := dispatcher.(*AsyncDispatcher); ok {
+		if err := async.Close(ctx); err != nil {
+			// workers that didn't drain may still be writing, the sinks stay open for them
+			fmt.Fprintf(os.Stderr, "logger: %d entries not flushed: %v\n", async.Len(), err)
+			return
+		}
+	}
+	if err := closeDispatcher(ctx, dispatcher); err != nil {
+		fmt.Fprintf(os.Stderr, "logger: closing sinks: %v\n", err)
+	}
+}
+
+// Dropped is the number of entries the async dispatcher discarded on overflow
+func Dropped() int64 {
+	if async, ok := l.dispatcher().(*AsyncDispatcher); ok {
+		return async.Dropped()
+	}
+	return 0
 }
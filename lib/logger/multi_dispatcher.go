@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MultiDispatcher hands every entry to all of its dispatchers in order, wrap a sink in a
+// LevelDispatcher to give it its own threshold
+type MultiDispatcher struct {
+	mu          sync.RWMutex
+	dispatchers []LogDispatcher
+}
+
+func NewMultiDispatcher(dispatchers ...LogDispatcher) *MultiDispatcher {
+	return &MultiDispatcher{dispatchers: dispatchers}
+}
+
+func (md *MultiDispatcher) Add(dispatcher LogDispatcher) {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	md.dispatchers = append(md.dispatchers, dispatcher)
+}
+
+func (md *MultiDispatcher) Dispatch(l *LogEntry) {
+	md.mu.RLock()
+	defer md.mu.RUnlock()
+	for _, dispatcher := range md.dispatchers {
+		dispatcher.Dispatch(l)
+	}
+}
+
+func (md *MultiDispatcher) DispatchBatch(entries []*LogEntry) {
+	md.mu.RLock()
+	defer md.mu.RUnlock()
+	for _, dispatcher := range md.dispatchers {
+		dispatchBatch(dispatcher, entries)
+	}
+}
+
+// Close closes every dispatcher that can be closed
+func (md *MultiDispatcher) Close(ctx context.Context) error {
+	md.mu.RLock()
+	defer md.mu.RUnlock()
+	var errs []error
+	for _, dispatcher := range md.dispatchers {
+		errs = append(errs, closeDispatcher(ctx, dispatcher))
+	}
+	return errors.Join(errs...)
+}
+
+// writingSince reports the oldest write in progress among the sinks
+func (md *MultiDispatcher) writingSince() time.Time {
+	md.mu.RLock()
+	defer md.mu.RUnlock()
+	var oldest time.Time
+	for _, dispatcher := range md.dispatchers {
+		tracker, ok := dispatcher.(writeTracker)
+		if !ok {
+			continue
+		}
+		if since := tracker.writingSince(); !since.IsZero() && (oldest.IsZero() || since.Before(oldest)) {
+			oldest = since
+		}
+	}
+	return oldest
+}
+
+func dispatchBatch(dispatcher LogDispatcher, entries []*LogEntry) {
+	if batcher, ok := dispatcher.(BatchDispatcher); ok {
+		batcher.DispatchBatch(entries)
+		return
+	}
+	for _, entry := range entries {
+		dispatcher.Dispatch(entry)
+	}
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func entryAt(level Level, msg string) *LogEntry {
+	return WithEntry().WithMessage(msg).withLevel(level)
+}
+
+func TestMultiDispatcherAppliesSinkLevels(t *testing.T) {
+	all, errorsOnly := &recordingDispatcher{}, &recordingDispatcher{}
+	multi := NewMultiDispatcher(all, NewLevelDispatcher(errorsOnly, ERROR))
+	multi.Dispatch(entryAt(DEBUG, "debug"))
+	multi.DispatchBatch([]*LogEntry{entryAt(INFO, "info"), entryAt(ERROR, "error")})
+
+	if got := all.messages(); len(got) != 3 {
+		t.Fatalf("unfiltered sink got %v, want all three", got)
+	}
+	if got := errorsOnly.messages(); len(got) != 1 || got[0] != "error" {
+		t.Fatalf("error sink got %v, want only error", got)
+	}
+	if err := multi.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !all.isClosed() || !errorsOnly.isClosed() {
+		t.Fatal("Close did not reach every sink")
+	}
+}
+
+func TestNewDispatcherFormatsEachSink(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath, textPath := filepath.Join(dir, "app.json"), filepath.Join(dir, "app.log")
+	dispatcher, err := NewDispatcher([]SinkConfig{
+		{Type: "file", Path: jsonPath, Format: "json"},
+		{Type: "file", Path: textPath, Level: "warn", Format: "logfmt"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Dispatch(entryAt(INFO, "started"))
+	dispatcher.Dispatch(entryAt(WARN, "slow"))
+	closeDispatcher(context.Background(), dispatcher)
+
+	jsonLog, _ := os.ReadFile(jsonPath)
+	if lines := strings.Count(string(jsonLog), "\n"); lines != 2 || !strings.Contains(string(jsonLog), `"Msg":"started"`) {
+		t.Fatalf("json sink wrote %d lines:\n%s", lines, jsonLog)
+	}
+	textLog, _ := os.ReadFile(textPath)
+	if strings.Contains(string(textLog), "started") || !strings.Contains(string(textLog), "msg=slow") {
+		t.Fatalf("warn logfmt sink wrote:\n%s", textLog)
+	}
+}
+
+func TestNewDispatcherRejectsBadSinks(t *testing.T) {
+	if _, err := NewDispatcher([]SinkConfig{{Type: "syslog"}}); !errors.Is(err, ErrUnknownSink) {
+		t.Fatalf("unknown type = %v, want ErrUnknownSink", err)
+	}
+	if _, err := NewDispatcher([]SinkConfig{{Type: "console", Format: "xml"}}); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("unknown format = %v, want ErrUnknownFormat", err)
+	}
+	if _, err := NewDispatcher([]SinkConfig{{Type: "console", Level: "loud"}}); !errors.Is(err, ErrUnknownLevel) {
+		t.Fatalf("unknown level = %v, want ErrUnknownLevel", err)
+	}
+}
@@ -0,0 +1,330 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+var ErrFileClosed = errors.New("log file is closed")
+
+// RotatingFile is an append-only log file that rotates by renaming the current file to
+// <name>-<timestamp><ext> and opening a fresh one. Renaming keeps the inode, so a tailer like
+// Filebeat finishes reading the old file before following the new one. Backups are compressed
+// and pruned in the background, the newest backup always stays uncompressed so a tailer that is
+// still behind never loses it.
+type RotatingFile struct {
+	mu           sync.Mutex
+	path         string
+	file         *os.File
+	size         int64
+	nextRotation time.Time
+
+	maxSize    int64
+	interval   time.Duration
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	mill     chan struct{}
+	millDone chan struct{}
+	closed   bool
+}
+
+type OptionalRotationConfig func(r *RotatingFile)
+
+// WithMaxSize rotates once the file would grow past maxBytes, 0 disables size rotation
+func WithMaxSize(maxBytes int64) OptionalRotationConfig {
+	return func(r *RotatingFile) {
+		r.maxSize = maxBytes
+	}
+}
+
+// WithRotationInterval rotates on every interval boundary, e.g. every full hour for time.Hour
+func WithRotationInterval(interval time.Duration) OptionalRotationConfig {
+	return func(r *RotatingFile) {
+		r.interval = interval
+	}
+}
+
+// WithMaxAge removes backups older than maxAge
+func WithMaxAge(maxAge time.Duration) OptionalRotationConfig {
+	return func(r *RotatingFile) {
+		r.maxAge = maxAge
+	}
+}
+
+// WithMaxBackups keeps at most maxBackups backups, the oldest go first
+func WithMaxBackups(maxBackups int) OptionalRotationConfig {
+	return func(r *RotatingFile) {
+		r.maxBackups = maxBackups
+	}
+}
+
+func WithCompress(compress bool) OptionalRotationConfig {
+	return func(r *RotatingFile) {
+		r.compress = compress
+	}
+}
+
+var openFiles sync.Map
+
+func NewRotatingFile(path string, optionalRotationConfigs ...OptionalRotationConfig) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:     path,
+		mill:     make(chan struct{}, 1),
+		millDone: make(chan struct{}),
+	}
+	for _, option := range optionalRotationConfigs {
+		option(r)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	go r.runMill()
+	openFiles.Store(r, struct{}{})
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	if r.interval > 0 {
+		r.nextRotation = time.Now().Truncate(r.interval).Add(r.interval)
+	}
+	return nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, ErrFileClosed
+	}
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) shouldRotate(incoming int64) bool {
+	intervalDue := r.interval > 0 && !time.Now().Before(r.nextRotation)
+	// an empty file never rotates, a single write larger than maxSize still has to go somewhere
+	if r.size == 0 {
+		if intervalDue {
+			r.nextRotation = time.Now().Truncate(r.interval).Add(r.interval)
+		}
+		return false
+	}
+	return intervalDue || (r.maxSize > 0 && r.size+incoming > r.maxSize)
+}
+
+// Rotate moves the current file aside now, whatever its size
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrFileClosed
+	}
+	return r.rotate()
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(r.path, r.nextBackupName()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		// keep logging to the same file rather than losing lines
+		if openErr := r.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	select {
+	case r.mill <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Reopen closes and reopens the path, for an external logrotate that already moved the file
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrFileClosed
+	}
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	err := r.file.Close()
+	r.mu.Unlock()
+	close(r.mill)
+	<-r.millDone
+	openFiles.Delete(r)
+	return err
+}
+
+// nextBackupName never reuses the name of an existing backup, rotations can come faster than the timestamp resolution
+func (r *RotatingFile) nextBackupName() string {
+	at := time.Now()
+	for {
+		name := r.backupName(at)
+		if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+			if _, err := os.Stat(name + ".gz"); errors.Is(err, os.ErrNotExist) {
+				return name
+			}
+		}
+		at = at.Add(time.Millisecond)
+	}
+}
+
+func (r *RotatingFile) backupName(at time.Time) string {
+	dir, base := filepath.Split(r.path)
+	ext := filepath.Ext(base)
+	return filepath.Join(dir, strings.TrimSuffix(base, ext)+"-"+at.Format(backupTimeFormat)+ext)
+}
+
+type backup struct {
+	path string
+	at   time.Time
+}
+
+// backups lists the rotated files of r, newest first
+func (r *RotatingFile) backups() ([]backup, error) {
+	dir, base := filepath.Split(r.path)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		at, err := time.Parse(backupTimeFormat, strings.TrimPrefix(stamp, prefix))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), at: at})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].at.After(backups[j].at)
+	})
+	return backups, nil
+}
+
+func (r *RotatingFile) runMill() {
+	defer close(r.millDone)
+	for range r.mill {
+		r.millOnce()
+	}
+}
+
+// millOnce applies retention and compresses every backup but the newest
+func (r *RotatingFile) millOnce() {
+	backups, err := r.backups()
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-r.maxAge)
+	for i, b := range backups {
+		expired := r.maxAge > 0 && b.at.Before(cutoff)
+		if (r.maxBackups > 0 && i >= r.maxBackups) || expired {
+			os.Remove(b.path)
+			continue
+		}
+		if r.compress && i > 0 && !strings.HasSuffix(b.path, ".gz") {
+			compressFile(b.path)
+		}
+	}
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	err = errors.Join(err, gz.Close(), dst.Close())
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// ReopenFiles reopens every open RotatingFile
+func ReopenFiles() error {
+	var errs []error
+	openFiles.Range(func(key, _ any) bool {
+		errs = append(errs, key.(*RotatingFile).Reopen())
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+// ReopenFilesOnSignal reopens the log files on every SIGHUP, for an external logrotate
+func ReopenFilesOnSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := ReopenFiles(); err != nil {
+				Dispatch(ERROR, "log file reopen failed: "+err.Error())
+			}
+		}
+	}()
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestRotatesOnSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(path, WithMaxSize(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an oversized write still goes to the empty file
+	f.Write([]byte("0123456789abc\n"))
+	f.Write([]byte("next\n"))
+	f.Write([]byte("more\n"))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := listDir(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("files = %v, want app.log and one backup", names)
+	}
+	if current := readLogs(t, path); current != "next\nmore\n" {
+		t.Fatalf("app.log = %q", current)
+	}
+	if _, err := f.Write([]byte("late\n")); !errors.Is(err, ErrFileClosed) {
+		t.Fatalf("write after Close = %v, want ErrFileClosed", err)
+	}
+}
+
+func TestRetentionKeepsTheNewestBackupsAndCompressesOlderOnes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	// a backup from long ago, removed by maxAge
+	stale := filepath.Join(dir, "app-"+time.Now().Add(-48*time.Hour).Format(backupTimeFormat)+".log")
+	os.WriteFile(stale, []byte("old\n"), 0o644)
+
+	f, err := NewRotatingFile(path, WithMaxBackups(2), WithMaxAge(24*time.Hour), WithCompress(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		f.Write([]byte("line\n"))
+		if err := f.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Close waits for the pending retention run
+	f.Close()
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want the newest two", listDir(t, dir))
+	}
+	if strings.HasSuffix(backups[0].path, ".gz") || !strings.HasSuffix(backups[1].path, ".gz") {
+		t.Fatalf("backups = %v, want only the older one compressed", listDir(t, dir))
+	}
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("backup past maxAge was kept")
+	}
+}
+
+func TestReopenFollowsAnExternalRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write([]byte("before\n"))
+	os.Rename(path, path+".1")
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("after\n"))
+	if current, moved := readLogs(t, path), readLogs(t, path+".1"); current != "after\n" || moved != "before\n" {
+		t.Fatalf("app.log = %q, app.log.1 = %q", current, moved)
+	}
+}
+
+func TestRotationConfigOptions(t *testing.T) {
+	if _, err := (&RotationConfig{Interval: "daily"}).options(); err == nil {
+		t.Fatal("bad interval accepted")
+	}
+	options, err := (&RotationConfig{MaxSizeMB: 1, Interval: "1h", MaxAge: "72h"}).options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &RotatingFile{}
+	for _, option := range options {
+		option(f)
+	}
+	if f.maxSize != 1<<20 || f.interval != time.Hour || f.maxAge != 72*time.Hour {
+		t.Fatalf("options gave maxSize %d, interval %s, maxAge %s", f.maxSize, f.interval, f.maxAge)
+	}
+}
@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSampleFirst      = 100
+	defaultSampleThereafter = 100
+	defaultSampleInterval   = time.Second
+)
+
+// SamplingDispatcher lets the first entries with the same level and message through in every
+// interval, then one in thereafter. At the end of an interval that suppressed anything it writes
+// a "suppressed K similar messages" entry per message, at the level of the suppressed entries.
+type SamplingDispatcher struct {
+	next       LogDispatcher
+	first      int64
+	thereafter int64
+	interval   time.Duration
+
+	mu       sync.Mutex
+	counters map[samplingKey]*samplingCounter
+	closed   bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	suppressed atomic.Int64
+}
+
+type samplingKey struct {
+	level Level
+	msg   string
+}
+
+type samplingCounter struct {
+	seen       int64
+	suppressed int64
+	// component of the first suppressed entry, the summary carries it so component levels apply
+	component string
+}
+
+type OptionalSamplingConfig func(s *SamplingDispatcher)
+
+// WithSampleFirst is the number of entries per message let through in every interval
+func WithSampleFirst(first int) OptionalSamplingConfig {
+	return func(s *SamplingDispatcher) {
+		if first >= 0 {
+			s.first = int64(first)
+		}
+	}
+}
+
+// WithSampleThereafter keeps one in thereafter entries past the first ones, 0 drops them all
+func WithSampleThereafter(thereafter int) OptionalSamplingConfig {
+	return func(s *SamplingDispatcher) {
+		if thereafter >= 0 {
+			s.thereafter = int64(thereafter)
+		}
+	}
+}
+
+func WithSampleInterval(interval time.Duration) OptionalSamplingConfig {
+	return func(s *SamplingDispatcher) {
+		if interval > 0 {
+			s.interval = interval
+		}
+	}
+}
+
+func NewSamplingDispatcher(next LogDispatcher, optionalSamplingConfigs ...OptionalSamplingConfig) *SamplingDispatcher {
+	sd := &SamplingDispatcher{
+		next:       next,
+		first:      defaultSampleFirst,
+		thereafter: defaultSampleThereafter,
+		interval:   defaultSampleInterval,
+		counters:   map[samplingKey]*samplingCounter{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, option := range optionalSamplingConfigs {
+		option(sd)
+	}
+	go sd.run()
+	return sd
+}
+
+func (sd *SamplingDispatcher) Dispatch(l *LogEntry) {
+	if sd.sample(l) {
+		sd.next.Dispatch(l)
+	}
+}
+
+func (sd *SamplingDispatcher) DispatchBatch(entries []*LogEntry) {
+	kept := make([]*LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if sd.sample(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) > 0 {
+		dispatchBatch(sd.next, kept)
+	}
+}
+
+func (sd *SamplingDispatcher) sample(l *LogEntry) bool {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if sd.closed {
+		return true
+	}
+	key := samplingKey{level: l.Level, msg: l.Msg}
+	counter, ok := sd.counters[key]
+	if !ok {
+		counter = &samplingCounter{}
+		sd.counters[key] = counter
+	}
+	counter.seen++
+	if counter.seen <= sd.first {
+		return true
+	}
+	if sd.thereafter > 0 && (counter.seen-sd.first-1)%sd.thereafter == 0 {
+		return true
+	}
+	if counter.suppressed == 0 {
+		component, _ := l.Fields.Get(componentField)
+		counter.component = component.str
+	}
+	counter.suppressed++
+	sd.suppressed.Add(1)
+	return false
+}
+
+func (sd *SamplingDispatcher) run() {
+	defer close(sd.done)
+	ticker := time.NewTicker(sd.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sd.summarize(false)
+		case <-sd.stop:
+			return
+		}
+	}
+}
+
+// summarize starts a new interval and writes the summaries of the one that ended
+func (sd *SamplingDispatcher) summarize(final bool) {
+	sd.mu.Lock()
+	counters := sd.counters
+	sd.counters = map[samplingKey]*samplingCounter{}
+	sd.closed = sd.closed || final
+	sd.mu.Unlock()
+
+	now := time.Now()
+	var summaries []*LogEntry
+	for key, counter := range counters {
+		if counter.suppressed == 0 {
+			continue
+		}
+		summary := WithEntry().
+			WithMessage("suppressed "+strconv.FormatInt(counter.suppressed, 10)+" similar messages").
+			WithFields(
+				String("sampled_message", key.msg),
+				Int64("suppressed", counter.suppressed),
+			).
+			withTime(now).
+			withLevel(key.level)
+		if counter.component != "" {
+			summary.WithField(componentField, counter.component)
+		}
+		summaries = append(summaries, summary)
+	}
+	if len(summaries) > 0 {
+		dispatchBatch(sd.next, summaries)
+	}
+}
+
+// Suppressed is the number of entries sampled out so far
+func (sd *SamplingDispatcher) Suppressed() int64 {
+	return sd.suppressed.Load()
+}
+
+// Close writes the pending summaries and closes next, entries dispatched afterwards are not sampled
+func (sd *SamplingDispatcher) Close(ctx context.Context) error {
+	closing := false
+	sd.closeOnce.Do(func() {
+		closing = true
+		close(sd.stop)
+		<-sd.done
+		sd.summarize(true)
+	})
+	if !closing {
+		return nil
+	}
+	return closeDispatcher(ctx, sd.next)
+}
+
+func (sd *SamplingDispatcher) writingSince() time.Time {
+	if tracker, ok := sd.next.(writeTracker); ok {
+		return tracker.writingSince()
+	}
+	return time.Time{}
+}
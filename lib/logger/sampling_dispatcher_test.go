@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// sampled dispatches n entries with the same message and returns what got through before Close
+func sampled(t *testing.T, n int, optionalSamplingConfigs ...OptionalSamplingConfig) (*recordingDispatcher, *SamplingDispatcher) {
+	t.Helper()
+	next := &recordingDispatcher{}
+	optionalSamplingConfigs = append(optionalSamplingConfigs, WithSampleInterval(time.Hour))
+	sd := NewSamplingDispatcher(next, optionalSamplingConfigs...)
+	for i := 0; i < n; i++ {
+		sd.Dispatch(entryAt(ERROR, "boom"))
+	}
+	return next, sd
+}
+
+func TestSamplingLetsTheBurstThroughThenOneInThereafter(t *testing.T) {
+	next, sd := sampled(t, 24, WithSampleFirst(5), WithSampleThereafter(10))
+	// 1 to 5, then 6 and 16
+	if got := len(next.messages()); got != 7 {
+		t.Fatalf("kept %d of 24, want 7", got)
+	}
+	if sd.Suppressed() != 17 {
+		t.Fatalf("suppressed %d, want 17", sd.Suppressed())
+	}
+	sd.DispatchBatch([]*LogEntry{entryAt(INFO, "boom"), entryAt(ERROR, "boom")})
+	if got := next.messages(); len(got) != 8 || next.entries[7].Level != INFO {
+		t.Fatalf("batch kept %v, want only the info entry, a new level and message pair", got[7:])
+	}
+
+	sd.Close(context.Background())
+	summary := next.entries[len(next.entries)-1]
+	if suppressed, _ := summary.Fields.Get("suppressed"); summary.Level != ERROR || suppressed.Value() != int64(18) {
+		t.Fatalf("summary = %s %+v, want error with 18 suppressed", summary.Msg, summary.Fields)
+	}
+	if !next.isClosed() {
+		t.Fatal("Close did not close next")
+	}
+}
+
+func TestSamplingSummaryKeepsTheComponent(t *testing.T) {
+	next := &recordingDispatcher{}
+	sd := NewSamplingDispatcher(next, WithSampleFirst(1), WithSampleThereafter(0), WithSampleInterval(time.Hour))
+	for i := 0; i < 3; i++ {
+		sd.Dispatch(entryAt(WARN, "slow").WithField(componentField, "peers"))
+	}
+	sd.Close(context.Background())
+	if len(next.entries) != 2 {
+		t.Fatalf("dispatched %v, want the first entry and a summary", next.messages())
+	}
+	if component, _ := next.entries[1].Fields.Get(componentField); component.String() != "peers" {
+		t.Fatalf("summary fields = %+v, want component peers", next.entries[1].Fields)
+	}
+}
+
+func TestSamplingConfigMinusOneMeansNone(t *testing.T) {
+	for name, test := range map[string]struct {
+		config SamplingConfig
+		kept   int
+	}{
+		"defaults":      {SamplingConfig{}, defaultSampleFirst + 1},
+		"no burst":      {SamplingConfig{First: -1, Thereafter: 50}, 3},
+		"no thereafter": {SamplingConfig{First: 20, Thereafter: -1}, 20},
+		"nothing":       {SamplingConfig{First: -1, Thereafter: -1}, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			options, err := test.config.options()
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, sd := sampled(t, 150, options...)
+			defer sd.Close(context.Background())
+			if got := len(next.messages()); got != test.kept {
+				t.Fatalf("kept %d of 150, want %d", got, test.kept)
+			}
+		})
+	}
+}
+
+func TestParseSamplingEnv(t *testing.T) {
+	if sampling, err := parseSamplingEnv("off"); sampling != nil || err != nil {
+		t.Fatalf("off = %+v, %v", sampling, err)
+	}
+	sampling, err := parseSamplingEnv("-1/10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *sampling != (SamplingConfig{First: -1, Thereafter: 10}) {
+		t.Fatalf("-1/10 = %+v", sampling)
+	}
+	if _, err := parseSamplingEnv("many"); err == nil {
+		t.Fatal("non numeric sampling accepted")
+	}
+}
@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// SlogHandler is a slog.Handler writing to a LogDispatcher, so libraries logging through slog end
+// up in the same sinks. Groups prefix the keys of their attrs, "request.id" for slog.Group("request", "id", ...).
+// The global and per-component levels apply as for Dispatch, the component being the value of
+// a "component" attr, from the record, the handler or a logger bound to the context by NewContext.
+type SlogHandler struct {
+	dispatcher LogDispatcher
+	fields     Fields
+	prefix     string
+	component  string
+}
+
+// NewSlogHandler writes to dispatcher, nil means the package logger and whatever Configure installed
+func NewSlogHandler(dispatcher LogDispatcher) *SlogHandler {
+	return &SlogHandler{dispatcher: dispatcher}
+}
+
+// LevelFromSlog maps the slog levels, anything between two of them rounds down
+func LevelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARN
+	default:
+		return ERROR
+	}
+}
+
+func LevelToSlog(level Level) slog.Level {
+	switch level {
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	component := h.component
+	if component == "" {
+		component = FromContext(ctx).component
+	}
+	if component == "" {
+		// a component attr of the record is only known to Handle, let through what some level could keep
+		return LevelFromSlog(level).severity() >= levels.Load().floor
+	}
+	return EnabledFor(component, LevelFromSlog(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	entry := WithEntry().WithMessage(record.Message)
+	// fields bound to a logger in ctx come first, then the handler attrs, then the record attrs
+	if bound := FromContext(ctx); len(bound.fields) > 0 {
+		entry.WithFields(bound.fields...)
+	}
+	entry.WithFields(h.fields...)
+	record.Attrs(func(attr slog.Attr) bool {
+		entry.Fields = appendSlogAttr(entry.Fields, h.prefix, attr)
+		return true
+	})
+	at := record.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	// the last component attr wins, Enabled only saw the handler's or the context's
+	component := h.component
+	if field, ok := entry.Fields.Get(componentField); ok && field.Kind == StringKind {
+		component = field.str
+	}
+	level := LevelFromSlog(record.Level)
+	if !EnabledFor(component, level) {
+		return nil
+	}
+	entry = entry.withTime(at).withLevel(level)
+	if h.dispatcher == nil {
+		l.dispatch(entry)
+		return nil
+	}
+	h.dispatcher.Dispatch(entry)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := *h
+	child.fields = make(Fields, len(h.fields), len(h.fields)+len(attrs))
+	copy(child.fields, h.fields)
+	for _, attr := range attrs {
+		child.fields = appendSlogAttr(child.fields, h.prefix, attr)
+		if h.prefix == "" && attr.Key == componentField {
+			child.component = attr.Value.Resolve().String()
+		}
+	}
+	return &child
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	child := *h
+	child.prefix = h.prefix + name + "."
+	return &child
+}
+
+func appendSlogAttr(fields Fields, prefix string, attr slog.Attr) Fields {
+	value := attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	key := prefix + attr.Key
+	switch value.Kind() {
+	case slog.KindGroup:
+		groupPrefix := prefix
+		// an inline group, slog.Group("", ...), adds its attrs without a prefix
+		if attr.Key != "" {
+			groupPrefix = key + "."
+		}
+		for _, member := range value.Group() {
+			fields = appendSlogAttr(fields, groupPrefix, member)
+		}
+		return fields
+	case slog.KindString:
+		return fields.set(String(key, value.String()))
+	case slog.KindInt64:
+		return fields.set(Int64(key, value.Int64()))
+	case slog.KindUint64:
+		if v := value.Uint64(); v <= math.MaxInt64 {
+			return fields.set(Int64(key, int64(v)))
+		}
+		return fields.set(Float(key, float64(value.Uint64())))
+	case slog.KindFloat64:
+		return fields.set(Float(key, value.Float64()))
+	case slog.KindBool:
+		return fields.set(Bool(key, value.Bool()))
+	case slog.KindDuration:
+		return fields.set(Duration(key, value.Duration()))
+	case slog.KindTime:
+		return fields.set(Time(key, value.Time()))
+	}
+	if err, ok := value.Any().(error); ok {
+		return fields.set(Field{Key: key, Kind: ErrorKind, obj: err})
+	}
+	return fields.set(Object(key, value.Any()))
+}
+
+// SlogDispatcher writes entries to a slog.Handler. Don't point it at slog.Default() once the
+// default logger uses a SlogHandler, the entries would loop back into the logger.
+type SlogDispatcher struct {
+	handler slog.Handler
+}
+
+func NewSlogDispatcher(handler slog.Handler) *SlogDispatcher {
+	return &SlogDispatcher{handler: handler}
+}
+
+func (sd *SlogDispatcher) Dispatch(l *LogEntry) {
+	level := LevelToSlog(l.Level)
+	ctx := context.Background()
+	if !sd.handler.Enabled(ctx, level) {
+		return
+	}
+	record := slog.NewRecord(l.Time, level, l.Msg, 0)
+	for _, field := range l.Fields {
+		record.AddAttrs(field.slogAttr())
+	}
+	sd.handler.Handle(ctx, record)
+}
+
+func (f Field) slogAttr() slog.Attr {
+	switch f.Kind {
+	case IntKind:
+		return slog.Int64(f.Key, f.num)
+	case FloatKind:
+		return slog.Float64(f.Key, f.float)
+	case BoolKind:
+		return slog.Bool(f.Key, f.num == 1)
+	case DurationKind:
+		return slog.Duration(f.Key, time.Duration(f.num))
+	case TimeKind:
+		return slog.Time(f.Key, f.time())
+	case ErrorKind:
+		return slog.Any(f.Key, f.obj)
+	case ObjectKind:
+		return slog.Any(f.Key, f.obj)
+	default:
+		return slog.String(f.Key, f.str)
+	}
+}
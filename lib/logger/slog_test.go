@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSlogLevelMapping(t *testing.T) {
+	for level, want := range map[slog.Level]Level{
+		slog.LevelDebug - 4: DEBUG,
+		slog.LevelDebug:     DEBUG,
+		slog.LevelInfo:      INFO,
+		slog.LevelInfo + 2:  INFO,
+		slog.LevelWarn:      WARN,
+		slog.LevelError - 1: WARN,
+		slog.LevelError:     ERROR,
+		slog.LevelError + 4: ERROR,
+	} {
+		if got := LevelFromSlog(level); got != want {
+			t.Errorf("LevelFromSlog(%s) = %s, want %s", level, got, want)
+		}
+	}
+	for _, level := range []Level{DEBUG, INFO, WARN, ERROR} {
+		if got := LevelFromSlog(LevelToSlog(level)); got != level {
+			t.Errorf("%s round-tripped to %s", level, got)
+		}
+	}
+}
+
+func TestSlogHandlerPrefixesGroups(t *testing.T) {
+	keepLevels(t)
+	SetLevel(DEBUG)
+	next := &recordingDispatcher{}
+	log := slog.New(NewSlogHandler(next)).WithGroup("request").With("id", "r1")
+	log.Warn("slow",
+		slog.Group("user", "name", "ada"),
+		slog.Group("", "inline", 1),
+		slog.Duration("took", time.Second),
+		slog.Any("err", errors.New("timeout")),
+		slog.Group("empty"),
+	)
+
+	if len(next.entries) != 1 {
+		t.Fatalf("dispatched %d entries, want 1", len(next.entries))
+	}
+	entry := next.entries[0]
+	if entry.Level != WARN || entry.Msg != "slow" {
+		t.Fatalf("entry = %+v", entry)
+	}
+	want := map[string]FieldKind{
+		"request.id":        StringKind,
+		"request.user.name": StringKind,
+		"request.inline":    IntKind,
+		"request.took":      DurationKind,
+		"request.err":       ErrorKind,
+	}
+	if len(entry.Fields) != len(want) {
+		t.Fatalf("fields = %v, want %d", entry.Fields, len(want))
+	}
+	for key, kind := range want {
+		if field, ok := entry.Fields.Get(key); !ok || field.Kind != kind {
+			t.Errorf("%s = %+v, want kind %d", key, field, kind)
+		}
+	}
+}
+
+func TestSlogHandlerFollowsComponentLevels(t *testing.T) {
+	keepLevels(t)
+	SetLevel(INFO)
+	SetComponentLevel("peers", ERROR)
+	next := &recordingDispatcher{}
+	peers := slog.New(NewSlogHandler(next)).With("component", "peers")
+	peers.Warn("dropped")
+	peers.Error("kept")
+	slog.New(NewSlogHandler(next)).Debug("dropped too")
+
+	if got := next.messages(); len(got) != 1 || got[0] != "kept" {
+		t.Fatalf("dispatched %v, want only kept", got)
+	}
+}
+
+func TestSlogHandlerTakesTheComponentFromRecordAndContext(t *testing.T) {
+	keepLevels(t)
+	SetLevel(INFO)
+	SetComponentLevel("peers", ERROR)
+	SetComponentLevel("cache", DEBUG)
+	next := &recordingDispatcher{}
+	log := slog.New(NewSlogHandler(next))
+	peersCtx := NewContext(context.Background(), Named("peers"))
+	log.Info("record attr", "component", "peers")
+	log.InfoContext(peersCtx, "context logger")
+	log.WarnContext(peersCtx, "context logger warn")
+	log.ErrorContext(peersCtx, "kept")
+	log.Debug("debug", "component", "cache")
+	log.Debug("dropped")
+
+	if got := next.messages(); len(got) != 2 || got[0] != "kept" || got[1] != "debug" {
+		t.Fatalf("dispatched %v, want kept and debug", got)
+	}
+}
+
+func TestSlogDispatcher(t *testing.T) {
+	var out bytes.Buffer
+	dispatcher := NewSlogDispatcher(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	dispatcher.Dispatch(entryAt(DEBUG, "hidden"))
+	dispatcher.Dispatch(entryAt(ERROR, "failed").WithFields(Int("attempt", 2), Bool("retry", true)))
+
+	if lines := bytes.Count(out.Bytes(), []byte("\n")); lines != 1 {
+		t.Fatalf("handler got %d records, want the debug entry filtered:\n%s", lines, out.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "failed" || record["level"] != "ERROR" || record["attempt"] != float64(2) || record["retry"] != true {
+		t.Fatalf("record = %v", record)
+	}
+}
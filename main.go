@@ -3,10 +3,40 @@ package main
 import (
 	"context"
 	"fmt"
+	"inmem/lib/health"
+	cache "inmem/lib/inmem-cache"
+	"inmem/lib/inmem-cache/admin"
+	"inmem/lib/inmem-cache/trace"
+	"inmem/lib/loadgen"
 	"inmem/lib/logger"
 	"inmem/shutdown"
 	to_do "inmem/src/to-do"
+	"log/slog"
+	"net/http"
 	_ "net/http/pprof"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// debugServerAddr stays on loopback, pprof and the admin api aren't meant to be reachable from other hosts
+	debugServerAddr = "127.0.0.1:6060"
+	// mixedTrafficQPSEnv runs the loadgen mixed workload against the todo store at that rate,
+	// it writes loadgen: keys into the store so it is unset outside of load tests
+	mixedTrafficQPSEnv = "TODO_LOADGEN_QPS"
+	// traceFileEnv names a file to record the todo store access trace into, unset disables recording
+	traceFileEnv = "TODO_TRACE_FILE"
+	// warmKeysEnv names a key list to preload, snapshotFileEnv a snapshot loaded at startup and written at shutdown
+	warmKeysEnv     = "TODO_WARM_KEYS"
+	snapshotFileEnv = "TODO_SNAPSHOT_FILE"
+	warmupTimeout   = 10 * time.Second
+
+	logWriteStuckAfter = 5 * time.Second
+	// logLevelsFileEnv names a json logger.LevelConfig, reloaded on SIGHUP
+	logLevelsFileEnv = "LOG_LEVELS_FILE"
+	// adminTokenEnv is the bearer token for the admin api writes and log level changes, unset keeps both read-only
+	adminTokenEnv = "ADMIN_TOKEN"
 )
 
 var (
@@ -14,9 +44,23 @@ var (
 )
 
 func main() {
+	// libraries logging through slog, and the standard log package with it, go to the logger sinks
+	slog.SetDefault(slog.New(logger.NewSlogHandler(nil)))
+	logger.ReopenFilesOnSignal()
+	if path := os.Getenv(logLevelsFileEnv); path != "" {
+		if err := logger.ReloadLevelsOnSignal(path); err != nil {
+			logger.Dispatch(logger.ERROR, err.Error())
+		}
+	}
+	// hooks run in order and main exits once shutDownHook fires, so the recorder, snapshot and log flush hooks go first
+	startTraceRecorder()
+	startWarmup()
+	shutdown.AddHook(logger.ShutdownHook)
 	shutdown.AddHook(shutDownHook)
 	logger.Dispatch(logger.INFO, "Main app has started running")
-	go to_do.TestCacheMixedTraffic5kQPS()
+	startDebugServer()
+	waitForWarmup()
+	startMixedTraffic()
 	fmt.Println("Listneing to shutDown channel")
 	<-shutDownChan
 }
@@ -25,3 +69,140 @@ func shutDownHook(ctx context.Context) {
 	logger.Dispatch(logger.INFO, "Main app has ended")
 	shutDownChan <- true
 }
+
+// startDebugServer serves pprof, health checks, log levels and the cache admin api on the default mux
+func startDebugServer() {
+	to_do.ToDoListStore.RegisterHealth(health.Default(), "todo")
+	health.Default().AddLivenessCheck("logger", logger.StuckWriterCheck(logWriteStuckAfter))
+	healthHandler := health.Default().Handler()
+	http.Handle("/healthz", healthHandler)
+	http.Handle("/readyz", healthHandler)
+	// log level changes and admin api writes need the bearer token from adminTokenEnv, without it both are read-only
+	adminConfig := admin.WithReadOnly()
+	var levelConfigs []logger.OptionalLevelHandlerConfig
+	if token := os.Getenv(adminTokenEnv); token != "" {
+		adminConfig = admin.WithAuth(admin.BearerToken(token))
+		levelConfigs = append(levelConfigs, logger.WithLevelAuth(admin.BearerToken(token)))
+	}
+	http.Handle("/loglevel", logger.LevelHandler(levelConfigs...))
+	adminHandler := admin.NewHandler(adminConfig)
+	adminHandler.Register("todo", to_do.ToDoListStore)
+	http.Handle("/admin/", http.StripPrefix("/admin", adminHandler))
+	go func() {
+		if err := http.ListenAndServe(debugServerAddr, nil); err != nil {
+			logger.Dispatch(logger.ERROR, err.Error())
+		}
+	}()
+}
+
+// startMixedTraffic drives the todo store with the mixed workload when mixedTrafficQPSEnv is set
+func startMixedTraffic() {
+	value := os.Getenv(mixedTrafficQPSEnv)
+	if value == "" {
+		return
+	}
+	qps, err := strconv.Atoi(value)
+	if err != nil || qps <= 0 {
+		logger.Dispatch(logger.ERROR, fmt.Sprintf("invalid %s=%q, mixed traffic disabled", mixedTrafficQPSEnv, value))
+		return
+	}
+	go runMixedTraffic(qps)
+}
+
+// runMixedTraffic drives the todo store with a mixed workload for a minute and logs the report
+func runMixedTraffic(qps int) {
+	report, err := loadgen.Run(context.Background(), to_do.ToDoListStore,
+		loadgen.WithQPS(qps),
+		loadgen.WithDuration(time.Minute),
+		loadgen.WithWorkers(64),
+	)
+	if err != nil {
+		logger.Dispatch(logger.ERROR, err.Error())
+		return
+	}
+	logger.Dispatch(logger.INFO, report.String())
+}
+
+// startTraceRecorder records the todo store accesses for offline replay with cmd/tracereplay
+func startTraceRecorder() {
+	path := os.Getenv(traceFileEnv)
+	if path == "" {
+		return
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		logger.Dispatch(logger.ERROR, err.Error())
+		return
+	}
+	recorder, err := trace.NewRecorder(file)
+	if err != nil {
+		file.Close()
+		logger.Dispatch(logger.ERROR, err.Error())
+		return
+	}
+	recorder.Attach(to_do.ToDoListStore)
+	shutdown.AddHook(func(ctx context.Context) {
+		if err := recorder.Close(); err != nil {
+			logger.Dispatch(logger.ERROR, err.Error())
+		}
+	})
+}
+
+// startWarmup preloads the todo store in the background, from the snapshot when there is one.
+// The store is marked not ready before it returns, waitForWarmup relies on that.
+func startWarmup() {
+	snapshotPath := os.Getenv(snapshotFileEnv)
+	if snapshotPath != "" {
+		shutdown.AddHook(func(ctx context.Context) {
+			writeSnapshot(snapshotPath)
+		})
+		if file, err := os.Open(snapshotPath); err == nil {
+			to_do.ToDoListStore.WarmFromSnapshotAsync(context.Background(), file, func(progress cache.WarmProgress, err error) {
+				file.Close()
+				logWarmup(progress, err)
+			})
+			return
+		}
+	}
+	keysPath := os.Getenv(warmKeysEnv)
+	if keysPath == "" {
+		return
+	}
+	keys, err := cache.KeysFromFile(keysPath)
+	if err != nil {
+		logger.Dispatch(logger.ERROR, err.Error())
+		return
+	}
+	to_do.ToDoListStore.WarmAsync(context.Background(), keys, to_do.GetToDoLoader, logWarmup)
+}
+
+// waitForWarmup holds startup until the warmup is done, traffic starts anyway after warmupTimeout
+func waitForWarmup() {
+	ctx, cancel := context.WithTimeout(context.Background(), warmupTimeout)
+	defer cancel()
+	if err := to_do.ToDoListStore.WaitReady(ctx); err != nil {
+		logger.Dispatch(logger.ERROR, "todo store warmup did not finish in time: "+err.Error())
+	}
+}
+
+func logWarmup(progress cache.WarmProgress, err error) {
+	message := fmt.Sprintf("todo store warmup total=%d loaded=%d skipped=%d failed=%d",
+		progress.Total, progress.Loaded, progress.Skipped, progress.Failed)
+	if err != nil {
+		logger.Dispatch(logger.ERROR, message+": "+err.Error())
+		return
+	}
+	logger.Dispatch(logger.INFO, message)
+}
+
+func writeSnapshot(path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		logger.Dispatch(logger.ERROR, err.Error())
+		return
+	}
+	defer file.Close()
+	if err := to_do.ToDoListStore.WriteSnapshot(file); err != nil {
+		logger.Dispatch(logger.ERROR, err.Error())
+	}
+}
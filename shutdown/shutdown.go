@@ -2,11 +2,14 @@ package shutdown
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"inmem/lib/health"
 	"inmem/lib/logger"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -17,16 +20,31 @@ var (
 	mu        sync.Mutex
 	callbacks []Callback
 	sigChan   = make(chan os.Signal, 1)
+
+	inProgress atomic.Bool
 )
 
+var ErrShuttingDown = errors.New("shutdown in progress")
+
 // default signals
 var shutdownSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
 
 func init() {
 	signal.Notify(sigChan, shutdownSignals...)
+	health.Default().AddReadinessCheck("shutdown", func(ctx context.Context) error {
+		if InProgress() {
+			return ErrShuttingDown
+		}
+		return nil
+	})
 	go listen()
 }
 
+// InProgress reports whether the shutdown hooks have started running
+func InProgress() bool {
+	return inProgress.Load()
+}
+
 // AddHook registers a shutdown callback
 func AddHook(cb Callback) {
 	mu.Lock()
@@ -42,6 +60,7 @@ func listen() {
 }
 
 func execute() {
+	inProgress.Store(true)
 
 	// context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -16,4 +16,4 @@ var optionalBigCacheConfigs = []big_cache.OptionalBigCacheConfig{
 }
 
 var bigCache = big_cache.CreateBigCache(optionalBigCacheConfigs...)
-var ToDoListStore = cache.GetCache(bigCache, CacheTTL, true)
+var ToDoListStore = cache.GetCache(bigCache, CacheTTL, true, cache.WithName("todo"))